@@ -0,0 +1,243 @@
+package prostar_pwm
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/simonvetter/modbus"
+)
+
+const (
+	// consecutive timeouts after which the connection is reopened, in case it is the connection
+	// rather than the devices that stopped responding
+	reopenAfterTimeouts = 3
+)
+
+var (
+	ErrBusClosed = errors.New("bus closed")
+)
+
+// DialFunc opens a connection and returns a Transport for it. If the Transport implements io.Closer,
+// it is closed when the Bus reopens the connection or is closed.
+type DialFunc func() (Transport, error)
+
+// Bus owns a connection and the lock that serializes access to it, and hands out a Dev for each unit
+// ID on the bus. The connection is opened on first use and reopened after an I/O error (such as a USB
+// adapter being unplugged or a TCP connection being reset) or repeated timeouts.
+type Bus struct {
+	dial      DialFunc
+	mutex     sync.Mutex
+	transport Transport
+	unitId    uint8
+	timeouts  int
+	closed    bool
+}
+
+func NewBus(dial DialFunc) *Bus {
+	return &Bus{
+		dial: dial,
+	}
+}
+
+// NewModbusBus returns a Bus that connects with a simonvetter/modbus client using config.
+func NewModbusBus(config modbus.ClientConfiguration) *Bus {
+	return NewBus(func() (Transport, error) {
+		client, err := modbus.NewClient(&config)
+		if err != nil {
+			return nil, err
+		}
+		err = client.Open()
+		if err != nil {
+			return nil, err
+		}
+		transport := NewModbusClientTransport(client)
+		transport.config = &config
+		return transport, nil
+	})
+}
+
+// Open opens the connection unless it is open already. Calling it is optional, but reports
+// connection errors early.
+func (bus *Bus) Open() error {
+	bus.mutex.Lock()
+	defer bus.mutex.Unlock()
+
+	_, err := bus.connect()
+	return err
+}
+
+func (bus *Bus) Close() error {
+	bus.mutex.Lock()
+	defer bus.mutex.Unlock()
+
+	bus.closed = true
+	return bus.disconnect()
+}
+
+func (bus *Bus) Dev(unitId uint8) *Dev {
+	return New(&busTransport{bus: bus}, unitId, &bus.mutex)
+}
+
+// Scan probes unitIds on the bus; see Scan.
+func (bus *Bus) Scan(ctx context.Context, unitIds []uint8, progress ScanProgressFunc) ([]ScanResult, error) {
+	return Scan(ctx, &busTransport{bus: bus}, &bus.mutex, unitIds, progress)
+}
+
+func (bus *Bus) connect() (Transport, error) {
+	if bus.closed {
+		return nil, ErrBusClosed
+	}
+	if bus.transport != nil {
+		return bus.transport, nil
+	}
+	transport, err := bus.dial()
+	if err != nil {
+		return nil, err
+	}
+	if bus.unitId != 0 {
+		err = transport.SetUnitId(bus.unitId)
+		if err != nil {
+			if closer, ok := transport.(io.Closer); ok {
+				_ = closer.Close()
+			}
+			return nil, err
+		}
+	}
+	bus.transport = transport
+	bus.timeouts = 0
+	return transport, nil
+}
+
+func (bus *Bus) disconnect() error {
+	transport := bus.transport
+	bus.transport = nil
+	if closer, ok := transport.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// checkError closes the connection if err suggests that it is broken, so that the next request
+// reopens it. Exceptions and corrupted responses show that the connection works. Timeouts of probes
+// are not counted, as probing unit IDs without a device is expected to time out.
+func (bus *Bus) checkError(err error, probe bool) {
+	switch {
+	case err == nil, isException(err):
+		bus.timeouts = 0
+	case isTimeout(err):
+		if probe {
+			return
+		}
+		bus.timeouts++
+		if bus.timeouts >= reopenAfterTimeouts {
+			_ = bus.disconnect()
+		}
+	case errors.Is(err, ErrBadCRC), errors.Is(err, ErrShortFrame), errors.Is(err, ErrProtocol):
+	default:
+		_ = bus.disconnect()
+	}
+}
+
+// busTransport is the Transport of the Devs handed out by a Bus. Like any Transport used by a Dev,
+// it is only called with the bus mutex held.
+type busTransport struct {
+	bus *Bus
+	ctx context.Context
+}
+
+func (t *busTransport) WithContext(ctx context.Context) Transport {
+	return &busTransport{
+		bus: t.bus,
+		ctx: ctx,
+	}
+}
+
+// connect returns the transport of the open connection, bound to the request context.
+func (t *busTransport) connect() (Transport, error) {
+	transport, err := t.bus.connect()
+	if err != nil {
+		return nil, err
+	}
+	if t.ctx != nil {
+		transport = withContext(transport, t.ctx)
+	}
+	return transport, nil
+}
+
+func (t *busTransport) SetUnitId(unitId uint8) error {
+	transport, err := t.connect()
+	if err != nil {
+		return err
+	}
+	err = transport.SetUnitId(unitId)
+	if err != nil {
+		return err
+	}
+	t.bus.unitId = unitId
+	return nil
+}
+
+func (t *busTransport) ReadRegister(addr uint16, regType RegisterType) (uint16, error) {
+	transport, err := t.connect()
+	if err != nil {
+		return 0, err
+	}
+	v, err := transport.ReadRegister(addr, regType)
+	t.bus.checkError(err, isProbe(t.ctx))
+	return v, err
+}
+
+func (t *busTransport) ReadRegisters(addr uint16, quantity uint16, regType RegisterType) ([]uint16, error) {
+	transport, err := t.connect()
+	if err != nil {
+		return nil, err
+	}
+	v, err := transport.ReadRegisters(addr, quantity, regType)
+	t.bus.checkError(err, isProbe(t.ctx))
+	return v, err
+}
+
+func (t *busTransport) WriteRegister(addr uint16, value uint16) error {
+	transport, err := t.connect()
+	if err != nil {
+		return err
+	}
+	err = transport.WriteRegister(addr, value)
+	t.bus.checkError(err, isProbe(t.ctx))
+	return err
+}
+
+func (t *busTransport) ReadCoil(addr uint16) (bool, error) {
+	transport, err := t.connect()
+	if err != nil {
+		return false, err
+	}
+	v, err := transport.ReadCoil(addr)
+	t.bus.checkError(err, isProbe(t.ctx))
+	return v, err
+}
+
+func (t *busTransport) WriteCoil(addr uint16, value bool) error {
+	transport, err := t.connect()
+	if err != nil {
+		return err
+	}
+	err = transport.WriteCoil(addr, value)
+	t.bus.checkError(err, isProbe(t.ctx))
+	return err
+}
+
+func (t *busTransport) ExecutePDU(functionCode uint8, data []byte) ([]byte, error) {
+	transport, err := t.connect()
+	if err != nil {
+		return nil, err
+	}
+	res, err := executePDU(transport, functionCode, data)
+	if errors.Is(err, ErrPDUNotSupported) {
+		return nil, err
+	}
+	t.bus.checkError(err, isProbe(t.ctx))
+	return res, err
+}
@@ -0,0 +1,54 @@
+package prostar_pwm
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/simonvetter/modbus"
+)
+
+func newTimingOutBus(dials *int) *Bus {
+	return NewBus(func() (Transport, error) {
+		*dials++
+		transport := newFakeTransport()
+		transport.err = modbus.ErrRequestTimedOut
+		return transport, nil
+	})
+}
+
+func TestBusReopenAfterTimeouts(t *testing.T) {
+	var dials int
+	bus := newTimingOutBus(&dials)
+	dev := bus.Dev(1)
+
+	for i := 0; i < reopenAfterTimeouts+1; i++ {
+		_, err := dev.ReadPWMSettings()
+		if !errors.Is(err, ErrTimeout) {
+			t.Fatalf("err = %v, want %v", err, ErrTimeout)
+		}
+	}
+	if dials != 2 {
+		t.Errorf("%d dials, want 2", dials)
+	}
+}
+
+func TestBusScanAbsent(t *testing.T) {
+	var dials int
+	bus := newTimingOutBus(&dials)
+
+	var unitIds []uint8
+	for unitId := uint8(1); unitId <= 10; unitId++ {
+		unitIds = append(unitIds, unitId)
+	}
+	results, err := bus.Scan(context.Background(), unitIds, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 0 {
+		t.Errorf("%d results, want 0", len(results))
+	}
+	if dials != 1 {
+		t.Errorf("%d dials, want 1", dials)
+	}
+}
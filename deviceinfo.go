@@ -0,0 +1,157 @@
+package prostar_pwm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/simonvetter/modbus"
+)
+
+// Basic device identification objects of Read Device Identification (function 0x2B/0x0E).
+const (
+	DeviceIdVendorName         uint8 = 0x00
+	DeviceIdProductCode        uint8 = 0x01
+	DeviceIdMajorMinorRevision uint8 = 0x02
+)
+
+const (
+	fcEncapsulatedInterfaceTransport = 0x2b
+	meiReadDeviceIdentification      = 0x0e
+	readDeviceIdBasic                = 0x01
+	moreFollows                      = 0xff
+)
+
+var (
+	ErrDeviceInfoNotAvailable = errors.New("device info not available")
+)
+
+// DeviceInfo identifies a controller. VendorName, ProductCode and Revision are the device
+// identification objects, which are only read if both the transport (see PDUTransport) and the
+// device support Read Device Identification.
+type DeviceInfo struct {
+	SoftwareVersion *string
+	HardwareVersion *string
+	SerialNumber    *string
+	VendorName      *string
+	ProductCode     *string
+	Revision        *string
+}
+
+func (dev *Dev) ReadDeviceInfo() (DeviceInfo, error) {
+	return dev.ReadDeviceInfoContext(context.Background())
+}
+
+// ReadDeviceInfoContext reads the version and serial number registers, and the device identification
+// objects if they are supported. The revision stands in for a software version register the device
+// does not have.
+func (dev *Dev) ReadDeviceInfoContext(ctx context.Context) (DeviceInfo, error) {
+	dev.mutex.Lock()
+	defer dev.mutex.Unlock()
+
+	err := dev.requestSetup(ctx)
+	if err != nil {
+		return DeviceInfo{}, err
+	}
+
+	eepromRegisters, err := dev.prefetch(dev.holdingRegisters.WithContext(ctx), 0xe0c0, 4)
+	if err != nil {
+		return DeviceInfo{}, withField("DeviceInfo", "SerialNumber", err)
+	}
+
+	var r DeviceInfo
+	errs := dev.newReadErrors()
+	inputFields := errs.fields("DeviceInfo", dev.inputRegisters.WithContext(ctx))
+	eepromFields := errs.fields("DeviceInfo", eepromRegisters)
+	holdingFields := errs.fields("DeviceInfo", dev.holdingRegisters.WithContext(ctx))
+
+	if v := inputFields.Uint16("SoftwareVersion", 0x0000); v != nil {
+		s := fmt.Sprintf("%02x.%02x", *v>>8, *v&0xff)
+		r.SoftwareVersion = &s
+	}
+	{
+		var b []byte
+		for addr := uint16(0xe0c0); addr <= 0xe0c3; addr++ {
+			v := eepromFields.Uint16("SerialNumber", addr)
+			if v == nil {
+				b = nil
+				break
+			}
+			// two ASCII digits per register, low byte first
+			b = append(b, byte(*v), byte(*v>>8))
+		}
+		s := strings.Trim(string(b), "\x00\xff ")
+		if s != "" {
+			r.SerialNumber = &s
+		}
+	}
+	if v := holdingFields.Uint16("HardwareVersion", 0xe0cc); v != nil {
+		s := fmt.Sprintf("%d.%02d", *v>>8, *v&0xff)
+		r.HardwareVersion = &s
+	}
+
+	if errs.stopped != nil {
+		return DeviceInfo{}, errs.stopped
+	}
+
+	objects, err := readDeviceIdentification(withContext(dev.transport, ctx))
+	switch {
+	case err == nil:
+		r.VendorName = deviceIdObject(objects, DeviceIdVendorName)
+		r.ProductCode = deviceIdObject(objects, DeviceIdProductCode)
+		r.Revision = deviceIdObject(objects, DeviceIdMajorMinorRevision)
+		if r.SoftwareVersion == nil {
+			r.SoftwareVersion = r.Revision
+		}
+	case errors.Is(err, ErrPDUNotSupported), errors.Is(err, modbus.ErrIllegalFunction):
+		// not supported by the transport or the device
+	default:
+		return DeviceInfo{}, fmt.Errorf("read device identification: %w", err)
+	}
+
+	if (r.SoftwareVersion == nil) && (r.HardwareVersion == nil) && (r.SerialNumber == nil) && (r.VendorName == nil) && (r.ProductCode == nil) {
+		return DeviceInfo{}, ErrDeviceInfoNotAvailable
+	}
+
+	return readResult(errs, r)
+}
+
+// readDeviceIdentification reads the basic device identification objects, keyed by object ID.
+func readDeviceIdentification(transport Transport) (map[uint8]string, error) {
+	objects := make(map[uint8]string)
+	objectId := DeviceIdVendorName
+	// a response that does not fit in one frame is continued from the next object ID
+	for i := 0; i < 256; i++ {
+		res, err := executePDU(transport, fcEncapsulatedInterfaceTransport, []byte{meiReadDeviceIdentification, readDeviceIdBasic, objectId})
+		if err != nil {
+			return nil, err
+		}
+		// MEI type, read device ID code, conformity level, more follows, next object ID and number of
+		// objects, followed by the objects
+		if (len(res) < 6) || (res[0] != meiReadDeviceIdentification) {
+			return nil, modbus.ErrProtocolError
+		}
+		b := res[6:]
+		for j := 0; j < int(res[5]); j++ {
+			if (len(b) < 2) || (len(b) < 2+int(b[1])) {
+				return nil, modbus.ErrShortFrame
+			}
+			objects[b[0]] = string(b[2 : 2+int(b[1])])
+			b = b[2+int(b[1]):]
+		}
+		if res[3] != moreFollows {
+			return objects, nil
+		}
+		objectId = res[4]
+	}
+	return nil, modbus.ErrProtocolError
+}
+
+func deviceIdObject(objects map[uint8]string, id uint8) *string {
+	v, ok := objects[id]
+	if !ok {
+		return nil
+	}
+	return &v
+}
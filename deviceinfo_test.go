@@ -0,0 +1,157 @@
+package prostar_pwm
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/simonvetter/modbus"
+)
+
+// deviceIdResponse returns a Read Device Identification response with the given objects.
+func deviceIdResponse(more bool, nextObjectId uint8, objects ...string) []byte {
+	res := []byte{meiReadDeviceIdentification, readDeviceIdBasic, 0x81, 0x00, nextObjectId, byte(len(objects) / 2)}
+	if more {
+		res[3] = moreFollows
+	}
+	for i := 0; i < len(objects); i += 2 {
+		res = append(res, objects[i][0], byte(len(objects[i+1])))
+		res = append(res, objects[i+1]...)
+	}
+	return res
+}
+
+func TestReadDeviceInfoIdentification(t *testing.T) {
+	transport := newFakeTransport()
+	transport.registers[0x0000] = 0x0213
+	var requests [][]byte
+	transport.pdu = func(functionCode uint8, data []byte) ([]byte, error) {
+		if functionCode != fcEncapsulatedInterfaceTransport {
+			t.Fatalf("function code = 0x%02x", functionCode)
+		}
+		requests = append(requests, data)
+		// the response is split in two, as if the objects did not fit in one frame
+		if data[2] == DeviceIdVendorName {
+			return deviceIdResponse(true, DeviceIdProductCode, "\x00", "Morningstar Corp."), nil
+		}
+		return deviceIdResponse(false, 0, "\x01", "PS-PWM", "\x02", "02.13"), nil
+	}
+	dev := New(transport, 1, &sync.Mutex{})
+
+	r, err := dev.ReadDeviceInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]byte{{0x0e, 0x01, 0x00}, {0x0e, 0x01, 0x01}}
+	if (len(requests) != 2) || !bytes.Equal(requests[0], want[0]) || !bytes.Equal(requests[1], want[1]) {
+		t.Errorf("requests = % x, want % x", requests, want)
+	}
+	if (r.VendorName == nil) || (*r.VendorName != "Morningstar Corp.") {
+		t.Errorf("VendorName = %v, want Morningstar Corp.", r.VendorName)
+	}
+	if (r.ProductCode == nil) || (*r.ProductCode != "PS-PWM") {
+		t.Errorf("ProductCode = %v, want PS-PWM", r.ProductCode)
+	}
+	if (r.Revision == nil) || (*r.Revision != "02.13") {
+		t.Errorf("Revision = %v, want 02.13", r.Revision)
+	}
+}
+
+// TestReadDeviceInfoRevision checks that the revision stands in for a software version register the
+// device does not have.
+func TestReadDeviceInfoRevision(t *testing.T) {
+	transport := newFakeTransport()
+	transport.failing[0x0000] = modbus.ErrIllegalDataAddress
+	transport.pdu = func(functionCode uint8, data []byte) ([]byte, error) {
+		return deviceIdResponse(false, 0, "\x02", "02.13"), nil
+	}
+	dev := New(transport, 1, &sync.Mutex{})
+
+	r, err := dev.ReadDeviceInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if (r.SoftwareVersion == nil) || (*r.SoftwareVersion != "02.13") {
+		t.Errorf("SoftwareVersion = %v, want 02.13", r.SoftwareVersion)
+	}
+}
+
+func TestReadDeviceInfoIdentificationNotSupported(t *testing.T) {
+	tests := []struct {
+		name string
+		pdu  func(functionCode uint8, data []byte) ([]byte, error)
+	}{
+		{name: "by the transport"},
+		{
+			name: "by the device",
+			pdu: func(functionCode uint8, data []byte) ([]byte, error) {
+				return nil, modbus.ErrIllegalFunction
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			transport := newFakeTransport()
+			transport.registers[0x0000] = 0x0213
+			transport.pdu = test.pdu
+			dev := New(transport, 1, &sync.Mutex{})
+
+			r, err := dev.ReadDeviceInfo()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (r.SoftwareVersion == nil) || (*r.SoftwareVersion != "02.13") {
+				t.Errorf("SoftwareVersion = %v, want 02.13", r.SoftwareVersion)
+			}
+			if (r.VendorName != nil) || (r.ProductCode != nil) || (r.Revision != nil) {
+				t.Errorf("identification objects = %v, %v, %v, want nil", r.VendorName, r.ProductCode, r.Revision)
+			}
+		})
+	}
+}
+
+func TestReadDeviceInfoIdentificationError(t *testing.T) {
+	transport := newFakeTransport()
+	transport.pdu = func(functionCode uint8, data []byte) ([]byte, error) {
+		return nil, modbus.ErrRequestTimedOut
+	}
+	dev := New(transport, 1, &sync.Mutex{})
+
+	_, err := dev.ReadDeviceInfo()
+	if !errors.Is(err, modbus.ErrRequestTimedOut) {
+		t.Errorf("err = %v, want %v", err, modbus.ErrRequestTimedOut)
+	}
+}
+
+func TestRTUResponseLength(t *testing.T) {
+	tests := []struct {
+		name   string
+		buf    []byte
+		length int
+	}{
+		{name: "too short to tell", buf: []byte{0x01, 0x03}},
+		{name: "exception", buf: []byte{0x01, 0x83, 0x02}, length: 5},
+		{name: "read holding registers", buf: []byte{0x01, 0x03, 0x04}, length: 9},
+		{name: "write single register", buf: []byte{0x01, 0x06, 0xe0}, length: 8},
+		{name: "device identification header incomplete", buf: []byte{0x01, 0x2b, 0x0e, 0x01, 0x81, 0x00, 0x00}},
+		{name: "device identification object incomplete", buf: []byte{0x01, 0x2b, 0x0e, 0x01, 0x81, 0x00, 0x00, 0x01, 0x00}},
+		{name: "device identification", buf: []byte{0x01, 0x2b, 0x0e, 0x01, 0x81, 0x00, 0x00, 0x02, 0x00, 0x01, 'M', 0x01, 0x02}, length: 17},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			length, err := rtuResponseLength(test.buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if length != test.length {
+				t.Errorf("length = %d, want %d", length, test.length)
+			}
+		})
+	}
+
+	_, err := rtuResponseLength([]byte{0x01, 0x41, 0x00})
+	if !errors.Is(err, modbus.ErrProtocolError) {
+		t.Errorf("err = %v, want %v", err, modbus.ErrProtocolError)
+	}
+}
@@ -1,644 +1,890 @@
 package prostar_pwm
 
 import (
+	"context"
 	"encoding/binary"
 	"errors"
+	"fmt"
 	"sync"
 
-	"github.com/simonvetter/modbus"
 	"github.com/x448/float16"
 )
 
+var (
+	ErrWriteVerificationFailed = errors.New("write verification failed")
+	ErrNotRepresentable        = errors.New("value not representable as float16")
+)
+
 type Dev struct {
-	mc               *modbus.ModbusClient
+	transport        Transport
 	unitId           uint8
 	mutex            *sync.Mutex
 	inputRegisters   *Registers
 	holdingRegisters *Registers
+	tolerant         bool
+	retryCounters    *retryCounters
 }
 
-func New(mc *modbus.ModbusClient, unitId uint8, mutex *sync.Mutex) *Dev {
+func New(transport Transport, unitId uint8, mutex *sync.Mutex) *Dev {
 	return &Dev{
-		mc:               mc,
+		transport:        transport,
 		unitId:           unitId,
 		mutex:            mutex,
-		inputRegisters:   NewRegisters(mc, modbus.INPUT_REGISTER),
-		holdingRegisters: NewRegisters(mc, modbus.HOLDING_REGISTER),
+		inputRegisters:   NewRegisters(transport, InputRegister),
+		holdingRegisters: NewRegisters(transport, HoldingRegister),
 	}
 }
 
-func (dev *Dev) requestSetup() error {
-	err := dev.mc.SetUnitId(dev.unitId)
+func (dev *Dev) requestSetup(ctx context.Context) error {
+	err := ctx.Err()
 	if err != nil {
 		return err
 	}
-	err = dev.mc.SetEncoding(modbus.BIG_ENDIAN, modbus.LOW_WORD_FIRST)
-	if err != nil {
-		return err
-	}
-	return nil
+	return withContext(dev.transport, ctx).SetUnitId(dev.unitId)
 }
 
 func (dev *Dev) ReadRawADCData() (RawADCData, error) {
+	return dev.ReadRawADCDataContext(context.Background())
+}
+
+func (dev *Dev) ReadRawADCDataContext(ctx context.Context) (RawADCData, error) {
 	dev.mutex.Lock()
 	defer dev.mutex.Unlock()
 
-	err := dev.requestSetup()
+	err := dev.requestSetup(ctx)
 	if err != nil {
 		return RawADCData{}, err
 	}
 
-	var r RawADCData
-
-	r.SupplyVoltage, err = dev.inputRegisters.ReadFloat16AsFloat32Ptr(0x0004)
-	if err != nil {
-		return RawADCData{}, err
-	}
-	r.GateDriveVoltage, err = dev.inputRegisters.ReadFloat16AsFloat32Ptr(0x0005)
-	if err != nil {
-		return RawADCData{}, err
-	}
-	r.MeterBusSupplyVoltage, err = dev.inputRegisters.ReadFloat16AsFloat32Ptr(0x0006)
-	if err != nil {
-		return RawADCData{}, err
-	}
-	r.InternalReferenceVoltage, err = dev.inputRegisters.ReadFloat16AsFloat32Ptr(0x0007)
-	if err != nil {
-		return RawADCData{}, err
-	}
-	r.NegativeSupplyRailForCurrentMeasurement, err = dev.inputRegisters.ReadFloat16AsFloat32Ptr(0x0008)
-	if err != nil {
-		return RawADCData{}, err
-	}
-	r.LoadFETGateVoltage, err = dev.inputRegisters.ReadFloat16AsFloat32Ptr(0x0009)
+	registers, err := dev.prefetch(dev.inputRegisters.WithContext(ctx), 0x0004, 7)
 	if err != nil {
-		return RawADCData{}, err
-	}
-	r.ArrayFETGateVoltage, err = dev.inputRegisters.ReadFloat16AsFloat32Ptr(0x000a)
-	if err != nil {
-		return RawADCData{}, err
+		return RawADCData{}, withGroup("RawADCData", err)
 	}
 
-	return r, nil
+	var r RawADCData
+	errs := dev.newReadErrors()
+	fields := errs.fields("RawADCData", registers)
+
+	r.SupplyVoltage = fields.Float16AsFloat32("SupplyVoltage", 0x0004)
+	r.GateDriveVoltage = fields.Float16AsFloat32("GateDriveVoltage", 0x0005)
+	r.MeterBusSupplyVoltage = fields.Float16AsFloat32("MeterBusSupplyVoltage", 0x0006)
+	r.InternalReferenceVoltage = fields.Float16AsFloat32("InternalReferenceVoltage", 0x0007)
+	r.NegativeSupplyRailForCurrentMeasurement = fields.Float16AsFloat32("NegativeSupplyRailForCurrentMeasurement", 0x0008)
+	r.LoadFETGateVoltage = fields.Float16AsFloat32("LoadFETGateVoltage", 0x0009)
+	r.ArrayFETGateVoltage = fields.Float16AsFloat32("ArrayFETGateVoltage", 0x000a)
+
+	return readResult(errs, r)
 }
 
 func (dev *Dev) ReadFilteredADCData() (FilteredADCData, error) {
+	return dev.ReadFilteredADCDataContext(context.Background())
+}
+
+func (dev *Dev) ReadFilteredADCDataContext(ctx context.Context) (FilteredADCData, error) {
 	dev.mutex.Lock()
 	defer dev.mutex.Unlock()
 
-	err := dev.requestSetup()
+	err := dev.requestSetup(ctx)
 	if err != nil {
 		return FilteredADCData{}, err
 	}
 
-	var r FilteredADCData
-
-	r.ArrayCurrent, err = dev.inputRegisters.ReadFloat16AsFloat32Ptr(0x0011)
-	if err != nil {
-		return FilteredADCData{}, err
-	}
-	r.BatteryTerminalVoltage, err = dev.inputRegisters.ReadFloat16AsFloat32Ptr(0x0012)
-	if err != nil {
-		return FilteredADCData{}, err
-	}
-	r.ArrayVoltage, err = dev.inputRegisters.ReadFloat16AsFloat32Ptr(0x0013)
-	if err != nil {
-		return FilteredADCData{}, err
-	}
-	r.LoadVoltage, err = dev.inputRegisters.ReadFloat16AsFloat32Ptr(0x0014)
-	if err != nil {
-		return FilteredADCData{}, err
-	}
-	r.LoadCurrent, err = dev.inputRegisters.ReadFloat16AsFloat32Ptr(0x0016)
-	if err != nil {
-		return FilteredADCData{}, err
-	}
-	r.BatterySenseVoltage, err = dev.inputRegisters.ReadFloat16AsFloat32Ptr(0x0017)
-	if err != nil {
-		return FilteredADCData{}, err
-	}
-	r.BatteryVoltage, err = dev.inputRegisters.ReadFloat16AsFloat32Ptr(0x0018)
+	registers, err := dev.prefetch(dev.inputRegisters.WithContext(ctx), 0x0011, 9)
 	if err != nil {
-		return FilteredADCData{}, err
-	}
-	r.BatteryCurrent, err = dev.inputRegisters.ReadFloat16AsFloat32Ptr(0x0019)
-	if err != nil {
-		return FilteredADCData{}, err
+		return FilteredADCData{}, withGroup("FilteredADCData", err)
 	}
 
-	return r, nil
+	var r FilteredADCData
+	errs := dev.newReadErrors()
+	fields := errs.fields("FilteredADCData", registers)
+
+	r.ArrayCurrent = fields.Float16AsFloat32("ArrayCurrent", 0x0011)
+	r.BatteryTerminalVoltage = fields.Float16AsFloat32("BatteryTerminalVoltage", 0x0012)
+	r.ArrayVoltage = fields.Float16AsFloat32("ArrayVoltage", 0x0013)
+	r.LoadVoltage = fields.Float16AsFloat32("LoadVoltage", 0x0014)
+	r.LoadCurrent = fields.Float16AsFloat32("LoadCurrent", 0x0016)
+	r.BatterySenseVoltage = fields.Float16AsFloat32("BatterySenseVoltage", 0x0017)
+	r.BatteryVoltage = fields.Float16AsFloat32("BatteryVoltage", 0x0018)
+	r.BatteryCurrent = fields.Float16AsFloat32("BatteryCurrent", 0x0019)
+
+	return readResult(errs, r)
 }
 
 func (dev *Dev) ReadTemperatureData() (TemperatureData, error) {
+	return dev.ReadTemperatureDataContext(context.Background())
+}
+
+func (dev *Dev) ReadTemperatureDataContext(ctx context.Context) (TemperatureData, error) {
 	dev.mutex.Lock()
 	defer dev.mutex.Unlock()
 
-	err := dev.requestSetup()
+	err := dev.requestSetup(ctx)
 	if err != nil {
 		return TemperatureData{}, err
 	}
 
-	var r TemperatureData
-
-	r.Heatsink, err = dev.inputRegisters.ReadFloat16AsFloat32Ptr(0x001a)
+	registers, err := dev.prefetch(dev.inputRegisters.WithContext(ctx), 0x001a, 4)
 	if err != nil {
-		return TemperatureData{}, err
-	}
-	r.Battery, err = dev.inputRegisters.ReadFloat16AsFloat32Ptr(0x001b)
-	if err != nil {
-		return TemperatureData{}, err
-	}
-	r.Ambient, err = dev.inputRegisters.ReadFloat16AsFloat32Ptr(0x001c)
-	if err != nil {
-		return TemperatureData{}, err
-	}
-	r.Remote, err = dev.inputRegisters.ReadFloat16AsFloat32Ptr(0x001d)
-	if err != nil {
-		return TemperatureData{}, err
+		return TemperatureData{}, withGroup("TemperatureData", err)
 	}
 
-	return r, nil
+	var r TemperatureData
+	errs := dev.newReadErrors()
+	fields := errs.fields("TemperatureData", registers)
+
+	r.Heatsink = fields.Float16AsFloat32("Heatsink", 0x001a)
+	r.Battery = fields.Float16AsFloat32("Battery", 0x001b)
+	r.Ambient = fields.Float16AsFloat32("Ambient", 0x001c)
+	r.Remote = fields.Float16AsFloat32("Remote", 0x001d)
+
+	return readResult(errs, r)
 }
 
 func (dev *Dev) ReadChargerStatus() (ChargerStatus, error) {
+	return dev.ReadChargerStatusContext(context.Background())
+}
+
+func (dev *Dev) ReadChargerStatusContext(ctx context.Context) (ChargerStatus, error) {
 	dev.mutex.Lock()
 	defer dev.mutex.Unlock()
 
-	err := dev.requestSetup()
+	err := dev.requestSetup(ctx)
 	if err != nil {
 		return ChargerStatus{}, err
 	}
 
-	var r ChargerStatus
-
-	{
-		v, err := dev.inputRegisters.ReadUint16Ptr(0x0021)
-		if err != nil {
-			return ChargerStatus{}, err
-		}
-		if v != nil {
-			v2 := ChargeState(*v)
-			r.ChargeState = &v2
-		}
-	}
-	{
-		v, err := dev.inputRegisters.ReadUint16Ptr(0x0022)
-		if err != nil {
-			return ChargerStatus{}, err
-		}
-		if v != nil {
-			v2 := ArrayFault(*v)
-			details := v2.Details()
-			r.ArrayFault = &details
-		}
-	}
-	r.BatteryVoltage, err = dev.inputRegisters.ReadFloat16AsFloat32Ptr(0x0023)
+	registers, err := dev.prefetch(dev.inputRegisters.WithContext(ctx), 0x0021, 13)
 	if err != nil {
-		return ChargerStatus{}, err
-	}
-	r.BatteryRegulatorReferenceVoltage, err = dev.inputRegisters.ReadFloat16AsFloat32Ptr(0x0024)
-	if err != nil {
-		return ChargerStatus{}, err
-	}
-	r.AhChargeResettable, err = dev.inputRegisters.ReadUint32AsFloat32Ptr(0x0026, WordOrderingHighFirst, 10)
-	if err != nil {
-		return ChargerStatus{}, err
-	}
-	r.AhChargeTotal, err = dev.inputRegisters.ReadUint32AsFloat32Ptr(0x0028, WordOrderingHighFirst, 10)
-	if err != nil {
-		return ChargerStatus{}, err
-	}
-	r.KWhChargeResettable, err = dev.inputRegisters.ReadUint16AsFloat32Ptr(0x002a, 10)
-	if err != nil {
-		return ChargerStatus{}, err
-	}
-	r.KWhChargeTotal, err = dev.inputRegisters.ReadUint16AsFloat32Ptr(0x002b, 10)
-	if err != nil {
-		return ChargerStatus{}, err
-	}
-	r.BatteryTemperatureFoldback100PercentOutputLimit, err = dev.inputRegisters.ReadFloat16AsFloat32Ptr(0x002c)
-	if err != nil {
-		return ChargerStatus{}, err
-	}
-	r.BatteryTemperatureFoldback0PercentOutputLimit, err = dev.inputRegisters.ReadFloat16AsFloat32Ptr(0x002d)
-	if err != nil {
-		return ChargerStatus{}, err
+		return ChargerStatus{}, withGroup("ChargerStatus", err)
 	}
 
-	return r, nil
+	var r ChargerStatus
+	errs := dev.newReadErrors()
+	fields := errs.fields("ChargerStatus", registers)
+
+	if v := fields.Uint16("ChargeState", 0x0021); v != nil {
+		v2 := ChargeState(*v)
+		r.ChargeState = &v2
+	}
+	if v := fields.Uint16("ArrayFault", 0x0022); v != nil {
+		v2 := ArrayFault(*v)
+		details := v2.Details()
+		r.ArrayFault = &details
+	}
+	r.BatteryVoltage = fields.Float16AsFloat32("BatteryVoltage", 0x0023)
+	r.BatteryRegulatorReferenceVoltage = fields.Float16AsFloat32("BatteryRegulatorReferenceVoltage", 0x0024)
+	r.AhChargeResettable = fields.Uint32AsFloat32("AhChargeResettable", 0x0026, WordOrderingHighFirst, 10)
+	r.AhChargeTotal = fields.Uint32AsFloat32("AhChargeTotal", 0x0028, WordOrderingHighFirst, 10)
+	r.KWhChargeResettable = fields.Uint16AsFloat32("KWhChargeResettable", 0x002a, 10)
+	r.KWhChargeTotal = fields.Uint16AsFloat32("KWhChargeTotal", 0x002b, 10)
+	r.BatteryTemperatureFoldback100PercentOutputLimit = fields.Float16AsFloat32("BatteryTemperatureFoldback100PercentOutputLimit", 0x002c)
+	r.BatteryTemperatureFoldback0PercentOutputLimit = fields.Float16AsFloat32("BatteryTemperatureFoldback0PercentOutputLimit", 0x002d)
+
+	return readResult(errs, r)
 }
 
 func (dev *Dev) ReadLoadStatus() (LoadStatus, error) {
+	return dev.ReadLoadStatusContext(context.Background())
+}
+
+func (dev *Dev) ReadLoadStatusContext(ctx context.Context) (LoadStatus, error) {
 	dev.mutex.Lock()
 	defer dev.mutex.Unlock()
 
-	err := dev.requestSetup()
+	err := dev.requestSetup(ctx)
 	if err != nil {
 		return LoadStatus{}, err
 	}
 
-	var r LoadStatus
-
-	{
-		v, err := dev.inputRegisters.ReadUint16Ptr(0x002e)
-		if err != nil {
-			return LoadStatus{}, err
-		}
-		if v != nil {
-			v2 := LoadState(*v)
-			r.LoadState = &v2
-		}
-	}
-	{
-		v, err := dev.inputRegisters.ReadUint16Ptr(0x002f)
-		if err != nil {
-			return LoadStatus{}, err
-		}
-		if v != nil {
-			v2 := LoadFault(*v)
-			details := v2.Details()
-			r.LoadFault = &details
-		}
-	}
-	r.LoadCurrentCompensatedLVDVoltage, err = dev.inputRegisters.ReadFloat16AsFloat32Ptr(0x0030)
-	if err != nil {
-		return LoadStatus{}, err
-	}
-	r.LoadHVDVoltage, err = dev.inputRegisters.ReadFloat16AsFloat32Ptr(0x0031)
+	registers, err := dev.prefetch(dev.inputRegisters.WithContext(ctx), 0x002e, 8)
 	if err != nil {
-		return LoadStatus{}, err
+		return LoadStatus{}, withGroup("LoadStatus", err)
 	}
-	r.AhLoadResettable, err = dev.inputRegisters.ReadUint32AsFloat32Ptr(0x0032, WordOrderingHighFirst, 10)
-	if err != nil {
-		return LoadStatus{}, err
+
+	var r LoadStatus
+	errs := dev.newReadErrors()
+	fields := errs.fields("LoadStatus", registers)
+
+	if v := fields.Uint16("LoadState", 0x002e); v != nil {
+		v2 := LoadState(*v)
+		r.LoadState = &v2
 	}
-	r.AhLoadTotal, err = dev.inputRegisters.ReadUint32AsFloat32Ptr(0x0034, WordOrderingHighFirst, 10)
-	if err != nil {
-		return LoadStatus{}, err
+	if v := fields.Uint16("LoadFault", 0x002f); v != nil {
+		v2 := LoadFault(*v)
+		details := v2.Details()
+		r.LoadFault = &details
 	}
+	r.LoadCurrentCompensatedLVDVoltage = fields.Float16AsFloat32("LoadCurrentCompensatedLVDVoltage", 0x0030)
+	r.LoadHVDVoltage = fields.Float16AsFloat32("LoadHVDVoltage", 0x0031)
+	r.AhLoadResettable = fields.Uint32AsFloat32("AhLoadResettable", 0x0032, WordOrderingHighFirst, 10)
+	r.AhLoadTotal = fields.Uint32AsFloat32("AhLoadTotal", 0x0034, WordOrderingHighFirst, 10)
 
-	return r, nil
+	return readResult(errs, r)
 }
 
 func (dev *Dev) ReadMiscData() (MiscData, error) {
+	return dev.ReadMiscDataContext(context.Background())
+}
+
+func (dev *Dev) ReadMiscDataContext(ctx context.Context) (MiscData, error) {
 	dev.mutex.Lock()
 	defer dev.mutex.Unlock()
 
-	err := dev.requestSetup()
+	err := dev.requestSetup(ctx)
 	if err != nil {
 		return MiscData{}, err
 	}
 
-	var r MiscData
-
-	r.Hourmeter, err = dev.inputRegisters.ReadUint32Ptr(0x0036, WordOrderingHighFirst)
+	statusRegisters, err := dev.prefetch(dev.inputRegisters.WithContext(ctx), 0x0036, 6)
 	if err != nil {
-		return MiscData{}, err
+		return MiscData{}, withGroup("MiscData", err)
 	}
-	{
-		v, err := dev.inputRegisters.ReadUint32Ptr(0x0038, WordOrderingHighFirst)
-		if err != nil {
-			return MiscData{}, err
-		}
-		if v != nil {
-			v2 := Alarm(*v)
-			details := v2.Details()
-			r.Alarm = &details
-		}
+	ledRegisters, err := dev.prefetch(dev.inputRegisters.WithContext(ctx), 0x004d, 2)
+	if err != nil {
+		return MiscData{}, withGroup("MiscData", err)
 	}
-	r.DIPSwitch, err = dev.inputRegisters.ReadUint16Ptr(0x003a)
-	{
-		v, err := dev.inputRegisters.ReadUint16Ptr(0x003b)
-		if err != nil {
-			return MiscData{}, err
-		}
-		if v != nil {
-			v2 := LEDState(*v)
-			r.LEDState = &v2
-		}
+
+	var r MiscData
+	errs := dev.newReadErrors()
+	statusFields := errs.fields("MiscData", statusRegisters)
+	ledFields := errs.fields("MiscData", ledRegisters)
+
+	r.Hourmeter = statusFields.Uint32("Hourmeter", 0x0036, WordOrderingHighFirst)
+	if v := statusFields.Uint32("Alarm", 0x0038, WordOrderingHighFirst); v != nil {
+		v2 := Alarm(*v)
+		details := v2.Details()
+		r.Alarm = &details
 	}
-	{
-		v, err := dev.inputRegisters.ReadUint16Ptr(0x004d)
-		if err != nil {
-			return MiscData{}, err
-		}
-		if v != nil {
-			v2 := ChargeStatusLEDState(*v)
-			r.ChargeStatusLEDState = &v2
-		}
+	if v := statusFields.Uint16("DIPSwitch", 0x003a); v != nil {
+		details := DIPSwitch(*v).Details()
+		r.DIPSwitch = v
+		r.DIPSwitchDetails = &details
 	}
-	r.LightingShouldBeOn, err = dev.inputRegisters.ReadUint16Ptr(0x004e)
-	if err != nil {
-		return MiscData{}, err
+	if v := statusFields.Uint16("LEDState", 0x003b); v != nil {
+		v2 := LEDState(*v)
+		r.LEDState = &v2
 	}
+	if v := ledFields.Uint16("ChargeStatusLEDState", 0x004d); v != nil {
+		v2 := ChargeStatusLEDState(*v)
+		r.ChargeStatusLEDState = &v2
+	}
+	r.LightingShouldBeOn = ledFields.Uint16("LightingShouldBeOn", 0x004e)
 
-	return r, nil
+	return readResult(errs, r)
 }
 
 func (dev *Dev) ReadChargeSettings() (ChargeSettings, error) {
+	return dev.ReadChargeSettingsContext(context.Background())
+}
+
+func (dev *Dev) ReadChargeSettingsContext(ctx context.Context) (ChargeSettings, error) {
 	dev.mutex.Lock()
 	defer dev.mutex.Unlock()
 
-	err := dev.requestSetup()
+	err := dev.requestSetup(ctx)
 	if err != nil {
 		return ChargeSettings{}, err
 	}
 
+	registers, err := dev.prefetch(dev.holdingRegisters.WithContext(ctx), 0xe000, 32)
+	if err != nil {
+		return ChargeSettings{}, withGroup("ChargeSettings", err)
+	}
+
 	var r ChargeSettings
+	errs := dev.newReadErrors()
+	fields := errs.fields("ChargeSettings", registers)
+
+	r.RegulationVoltageAt25C = fields.Float16AsFloat32("RegulationVoltageAt25C", 0xe000)
+	r.FloatVoltageAt25C = fields.Float16AsFloat32("FloatVoltageAt25C", 0xe001)
+	r.TimeBeforeEnteringFloat = fields.Uint16("TimeBeforeEnteringFloat", 0xe002)
+	r.TimeBeforeEnteringFloatDueToLowBattery = fields.Uint16("TimeBeforeEnteringFloatDueToLowBattery", 0xe003)
+	r.VoltageTriggerForLowBatteryFloatTime = fields.Float16AsFloat32("VoltageTriggerForLowBatteryFloatTime", 0xe004)
+	r.VoltageToCancelFloat = fields.Float16AsFloat32("VoltageToCancelFloat", 0xe005)
+	r.ExitFloatTime = fields.Uint16("ExitFloatTime", 0xe006)
+	r.EqualizeVoltageAt25C = fields.Float16AsFloat32("EqualizeVoltageAt25C", 0xe007)
+	r.DaysBetweenEQCycles = fields.Uint16("DaysBetweenEQCycles", 0xe008)
+	r.EqualizeTimeLimitAboveEVReg = fields.Uint16("EqualizeTimeLimitAboveEVReg", 0xe009)
+	r.EqualizeTimeLimitAtEVEq = fields.Uint16("EqualizeTimeLimitAtEVEq", 0xe00a)
+	r.ReferenceChargeVoltageLimit = fields.Float16AsFloat32("ReferenceChargeVoltageLimit", 0xe010)
+	r.TemperatureCompensationCoefficient = fields.Float16AsFloat32("TemperatureCompensationCoefficient", 0xe01a)
+	r.HighVoltageDisconnectAt25C = fields.Float16AsFloat32("HighVoltageDisconnectAt25C", 0xe01b)
+	r.HighVoltageReconnect = fields.Float16AsFloat32("HighVoltageReconnect", 0xe01c)
+	r.MaximumChargeVoltageReference = fields.Float16AsFloat32("MaximumChargeVoltageReference", 0xe01d)
+	r.MaxBatteryTempCompensationLimit = fields.Uint16AsInt16("MaxBatteryTempCompensationLimit", 0xe01e)
+	r.MinBatteryTempCompensationLimit = fields.Uint16AsInt16("MinBatteryTempCompensationLimit", 0xe01f)
+
+	return readResult(errs, r)
+}
+
+func (dev *Dev) WriteChargeSettings(s ChargeSettings) error {
+	return dev.WriteChargeSettingsContext(context.Background(), s)
+}
 
-	r.RegulationVoltageAt25C, err = dev.holdingRegisters.ReadFloat16AsFloat32Ptr(0xe000)
+func (dev *Dev) WriteChargeSettingsContext(ctx context.Context, s ChargeSettings) error {
+	dev.mutex.Lock()
+	defer dev.mutex.Unlock()
+
+	err := dev.requestSetup(ctx)
 	if err != nil {
-		return ChargeSettings{}, err
+		return err
 	}
-	r.FloatVoltageAt25C, err = dev.holdingRegisters.ReadFloat16AsFloat32Ptr(0xe001)
+
+	registers := dev.holdingRegisters.WithContext(ctx)
+
+	err = registers.WriteFloat32AsFloat16Ptr(0xe000, s.RegulationVoltageAt25C)
 	if err != nil {
-		return ChargeSettings{}, err
+		return withField("ChargeSettings", "RegulationVoltageAt25C", err)
 	}
-	r.TimeBeforeEnteringFloat, err = dev.holdingRegisters.ReadUint16Ptr(0xe002)
+	err = registers.WriteFloat32AsFloat16Ptr(0xe001, s.FloatVoltageAt25C)
 	if err != nil {
-		return ChargeSettings{}, err
+		return withField("ChargeSettings", "FloatVoltageAt25C", err)
 	}
-	r.TimeBeforeEnteringFloatDueToLowBattery, err = dev.holdingRegisters.ReadUint16Ptr(0xe003)
+	err = registers.WriteUint16Ptr(0xe002, s.TimeBeforeEnteringFloat)
 	if err != nil {
-		return ChargeSettings{}, err
+		return withField("ChargeSettings", "TimeBeforeEnteringFloat", err)
 	}
-	r.VoltageTriggerForLowBatteryFloatTime, err = dev.holdingRegisters.ReadFloat16AsFloat32Ptr(0xe004)
+	err = registers.WriteUint16Ptr(0xe003, s.TimeBeforeEnteringFloatDueToLowBattery)
 	if err != nil {
-		return ChargeSettings{}, err
+		return withField("ChargeSettings", "TimeBeforeEnteringFloatDueToLowBattery", err)
 	}
-	r.VoltageToCancelFloat, err = dev.holdingRegisters.ReadFloat16AsFloat32Ptr(0xe005)
+	err = registers.WriteFloat32AsFloat16Ptr(0xe004, s.VoltageTriggerForLowBatteryFloatTime)
 	if err != nil {
-		return ChargeSettings{}, err
+		return withField("ChargeSettings", "VoltageTriggerForLowBatteryFloatTime", err)
 	}
-	r.ExitFloatTime, err = dev.holdingRegisters.ReadUint16Ptr(0xe006)
+	err = registers.WriteFloat32AsFloat16Ptr(0xe005, s.VoltageToCancelFloat)
 	if err != nil {
-		return ChargeSettings{}, err
+		return withField("ChargeSettings", "VoltageToCancelFloat", err)
 	}
-	r.EqualizeVoltageAt25C, err = dev.holdingRegisters.ReadFloat16AsFloat32Ptr(0xe007)
+	err = registers.WriteUint16Ptr(0xe006, s.ExitFloatTime)
 	if err != nil {
-		return ChargeSettings{}, err
+		return withField("ChargeSettings", "ExitFloatTime", err)
 	}
-	r.DaysBetweenEQCycles, err = dev.holdingRegisters.ReadUint16Ptr(0xe008)
+	err = registers.WriteFloat32AsFloat16Ptr(0xe007, s.EqualizeVoltageAt25C)
 	if err != nil {
-		return ChargeSettings{}, err
+		return withField("ChargeSettings", "EqualizeVoltageAt25C", err)
 	}
-	r.EqualizeTimeLimitAboveEVReg, err = dev.holdingRegisters.ReadUint16Ptr(0xe009)
+	err = registers.WriteUint16Ptr(0xe008, s.DaysBetweenEQCycles)
 	if err != nil {
-		return ChargeSettings{}, err
+		return withField("ChargeSettings", "DaysBetweenEQCycles", err)
 	}
-	r.EqualizeTimeLimitAtEVEq, err = dev.holdingRegisters.ReadUint16Ptr(0xe00a)
+	err = registers.WriteUint16Ptr(0xe009, s.EqualizeTimeLimitAboveEVReg)
 	if err != nil {
-		return ChargeSettings{}, err
+		return withField("ChargeSettings", "EqualizeTimeLimitAboveEVReg", err)
 	}
-	r.ReferenceChargeVoltageLimit, err = dev.holdingRegisters.ReadFloat16AsFloat32Ptr(0xe010)
+	err = registers.WriteUint16Ptr(0xe00a, s.EqualizeTimeLimitAtEVEq)
 	if err != nil {
-		return ChargeSettings{}, err
+		return withField("ChargeSettings", "EqualizeTimeLimitAtEVEq", err)
 	}
-	r.TemperatureCompensationCoefficient, err = dev.holdingRegisters.ReadFloat16AsFloat32Ptr(0xe01a)
+	err = registers.WriteFloat32AsFloat16Ptr(0xe010, s.ReferenceChargeVoltageLimit)
 	if err != nil {
-		return ChargeSettings{}, err
+		return withField("ChargeSettings", "ReferenceChargeVoltageLimit", err)
 	}
-	r.HighVoltageDisconnectAt25C, err = dev.holdingRegisters.ReadFloat16AsFloat32Ptr(0xe01b)
+	err = registers.WriteFloat32AsFloat16Ptr(0xe01a, s.TemperatureCompensationCoefficient)
 	if err != nil {
-		return ChargeSettings{}, err
+		return withField("ChargeSettings", "TemperatureCompensationCoefficient", err)
 	}
-	r.HighVoltageReconnect, err = dev.holdingRegisters.ReadFloat16AsFloat32Ptr(0xe01c)
+	err = registers.WriteFloat32AsFloat16Ptr(0xe01b, s.HighVoltageDisconnectAt25C)
 	if err != nil {
-		return ChargeSettings{}, err
+		return withField("ChargeSettings", "HighVoltageDisconnectAt25C", err)
 	}
-	r.MaximumChargeVoltageReference, err = dev.holdingRegisters.ReadFloat16AsFloat32Ptr(0xe01d)
+	err = registers.WriteFloat32AsFloat16Ptr(0xe01c, s.HighVoltageReconnect)
 	if err != nil {
-		return ChargeSettings{}, err
+		return withField("ChargeSettings", "HighVoltageReconnect", err)
 	}
-	r.MaxBatteryTempCompensationLimit, err = dev.holdingRegisters.ReadUint16AsInt16Ptr(0xe01e)
+	err = registers.WriteFloat32AsFloat16Ptr(0xe01d, s.MaximumChargeVoltageReference)
 	if err != nil {
-		return ChargeSettings{}, err
+		return withField("ChargeSettings", "MaximumChargeVoltageReference", err)
 	}
-	r.MinBatteryTempCompensationLimit, err = dev.holdingRegisters.ReadUint16AsInt16Ptr(0xe01f)
+	err = registers.WriteInt16AsUint16Ptr(0xe01e, s.MaxBatteryTempCompensationLimit)
 	if err != nil {
-		return ChargeSettings{}, err
+		return withField("ChargeSettings", "MaxBatteryTempCompensationLimit", err)
+	}
+	err = registers.WriteInt16AsUint16Ptr(0xe01f, s.MinBatteryTempCompensationLimit)
+	if err != nil {
+		return withField("ChargeSettings", "MinBatteryTempCompensationLimit", err)
 	}
 
-	return r, nil
+	return nil
 }
 
 func (dev *Dev) ReadLoadSettings() (LoadSettings, error) {
+	return dev.ReadLoadSettingsContext(context.Background())
+}
+
+func (dev *Dev) ReadLoadSettingsContext(ctx context.Context) (LoadSettings, error) {
 	dev.mutex.Lock()
 	defer dev.mutex.Unlock()
 
-	err := dev.requestSetup()
+	err := dev.requestSetup(ctx)
 	if err != nil {
 		return LoadSettings{}, err
 	}
 
+	registers, err := dev.prefetch(dev.holdingRegisters.WithContext(ctx), 0xe022, 6)
+	if err != nil {
+		return LoadSettings{}, withGroup("LoadSettings", err)
+	}
+
 	var r LoadSettings
+	errs := dev.newReadErrors()
+	fields := errs.fields("LoadSettings", registers)
+
+	r.LowVoltageDisconnect = fields.Float16AsFloat32("LowVoltageDisconnect", 0xe022)
+	r.LowVoltageReconnect = fields.Float16AsFloat32("LowVoltageReconnect", 0xe023)
+	r.LoadHighVoltageDisconnect = fields.Float16AsFloat32("LoadHighVoltageDisconnect", 0xe024)
+	r.LoadHighVoltageReconnect = fields.Float16AsFloat32("LoadHighVoltageReconnect", 0xe025)
+	r.LVDLoadCurrentCompensation = fields.Float16AsFloat32("LVDLoadCurrentCompensation", 0xe026)
+	r.LVDWarningTimeout = fields.Uint16("LVDWarningTimeout", 0xe027)
+
+	return readResult(errs, r)
+}
+
+func (dev *Dev) WriteLoadSettings(s LoadSettings) error {
+	return dev.WriteLoadSettingsContext(context.Background(), s)
+}
+
+func (dev *Dev) WriteLoadSettingsContext(ctx context.Context, s LoadSettings) error {
+	dev.mutex.Lock()
+	defer dev.mutex.Unlock()
 
-	r.LowVoltageDisconnect, err = dev.holdingRegisters.ReadFloat16AsFloat32Ptr(0xe022)
+	err := dev.requestSetup(ctx)
 	if err != nil {
-		return LoadSettings{}, err
+		return err
 	}
-	r.LowVoltageReconnect, err = dev.holdingRegisters.ReadFloat16AsFloat32Ptr(0xe023)
+
+	registers := dev.holdingRegisters.WithContext(ctx)
+
+	err = registers.WriteFloat32AsFloat16Ptr(0xe022, s.LowVoltageDisconnect)
 	if err != nil {
-		return LoadSettings{}, err
+		return withField("LoadSettings", "LowVoltageDisconnect", err)
 	}
-	r.LoadHighVoltageDisconnect, err = dev.holdingRegisters.ReadFloat16AsFloat32Ptr(0xe024)
+	err = registers.WriteFloat32AsFloat16Ptr(0xe023, s.LowVoltageReconnect)
 	if err != nil {
-		return LoadSettings{}, err
+		return withField("LoadSettings", "LowVoltageReconnect", err)
 	}
-	r.LoadHighVoltageReconnect, err = dev.holdingRegisters.ReadFloat16AsFloat32Ptr(0xe025)
+	err = registers.WriteFloat32AsFloat16Ptr(0xe024, s.LoadHighVoltageDisconnect)
 	if err != nil {
-		return LoadSettings{}, err
+		return withField("LoadSettings", "LoadHighVoltageDisconnect", err)
 	}
-	r.LVDLoadCurrentCompensation, err = dev.holdingRegisters.ReadFloat16AsFloat32Ptr(0xe026)
+	err = registers.WriteFloat32AsFloat16Ptr(0xe025, s.LoadHighVoltageReconnect)
 	if err != nil {
-		return LoadSettings{}, err
+		return withField("LoadSettings", "LoadHighVoltageReconnect", err)
 	}
-	r.LVDWarningTimeout, err = dev.holdingRegisters.ReadUint16Ptr(0xe027)
+	err = registers.WriteFloat32AsFloat16Ptr(0xe026, s.LVDLoadCurrentCompensation)
 	if err != nil {
-		return LoadSettings{}, err
+		return withField("LoadSettings", "LVDLoadCurrentCompensation", err)
+	}
+	err = registers.WriteUint16Ptr(0xe027, s.LVDWarningTimeout)
+	if err != nil {
+		return withField("LoadSettings", "LVDWarningTimeout", err)
 	}
 
-	return r, nil
+	return nil
 }
 
 func (dev *Dev) ReadMiscSettings() (MiscSettings, error) {
+	return dev.ReadMiscSettingsContext(context.Background())
+}
+
+func (dev *Dev) ReadMiscSettingsContext(ctx context.Context) (MiscSettings, error) {
 	dev.mutex.Lock()
 	defer dev.mutex.Unlock()
 
-	err := dev.requestSetup()
+	err := dev.requestSetup(ctx)
 	if err != nil {
 		return MiscSettings{}, err
 	}
 
+	registers, err := dev.prefetch(dev.holdingRegisters.WithContext(ctx), 0xe030, 6)
+	if err != nil {
+		return MiscSettings{}, withGroup("MiscSettings", err)
+	}
+
 	var r MiscSettings
+	errs := dev.newReadErrors()
+	fields := errs.fields("MiscSettings", registers)
+
+	r.LEDGreenToGreenAndYellowLimit = fields.Float16AsFloat32("LEDGreenToGreenAndYellowLimit", 0xe030)
+	r.LEDGreenAndYellowToYellowLimit = fields.Float16AsFloat32("LEDGreenAndYellowToYellowLimit", 0xe031)
+	r.LEDYellowToYellowAndRedLimit = fields.Float16AsFloat32("LEDYellowToYellowAndRedLimit", 0xe032)
+	r.LEDYellowAndRedToRedFlashingLimit = fields.Float16AsFloat32("LEDYellowAndRedToRedFlashingLimit", 0xe033)
+	r.ModbusID = fields.Uint16("ModbusID", 0xe034)
+	r.MeterbusID = fields.Uint16("MeterbusID", 0xe035)
+
+	return readResult(errs, r)
+}
+
+func (dev *Dev) WriteMiscSettings(s MiscSettings) error {
+	return dev.WriteMiscSettingsContext(context.Background(), s)
+}
+
+func (dev *Dev) WriteMiscSettingsContext(ctx context.Context, s MiscSettings) error {
+	dev.mutex.Lock()
+	defer dev.mutex.Unlock()
 
-	r.LEDGreenToGreenAndYellowLimit, err = dev.holdingRegisters.ReadFloat16AsFloat32Ptr(0xe030)
+	err := dev.requestSetup(ctx)
 	if err != nil {
-		return MiscSettings{}, err
+		return err
 	}
-	r.LEDGreenAndYellowToYellowLimit, err = dev.holdingRegisters.ReadFloat16AsFloat32Ptr(0xe031)
+
+	registers := dev.holdingRegisters.WithContext(ctx)
+
+	err = registers.WriteFloat32AsFloat16Ptr(0xe030, s.LEDGreenToGreenAndYellowLimit)
 	if err != nil {
-		return MiscSettings{}, err
+		return withField("MiscSettings", "LEDGreenToGreenAndYellowLimit", err)
 	}
-	r.LEDYellowToYellowAndRedLimit, err = dev.holdingRegisters.ReadFloat16AsFloat32Ptr(0xe032)
+	err = registers.WriteFloat32AsFloat16Ptr(0xe031, s.LEDGreenAndYellowToYellowLimit)
 	if err != nil {
-		return MiscSettings{}, err
+		return withField("MiscSettings", "LEDGreenAndYellowToYellowLimit", err)
 	}
-	r.LEDYellowAndRedToRedFlashingLimit, err = dev.holdingRegisters.ReadFloat16AsFloat32Ptr(0xe033)
+	err = registers.WriteFloat32AsFloat16Ptr(0xe032, s.LEDYellowToYellowAndRedLimit)
 	if err != nil {
-		return MiscSettings{}, err
+		return withField("MiscSettings", "LEDYellowToYellowAndRedLimit", err)
 	}
-	r.ModbusID, err = dev.holdingRegisters.ReadUint16Ptr(0xe034)
+	err = registers.WriteFloat32AsFloat16Ptr(0xe033, s.LEDYellowAndRedToRedFlashingLimit)
 	if err != nil {
-		return MiscSettings{}, err
+		return withField("MiscSettings", "LEDYellowAndRedToRedFlashingLimit", err)
 	}
-	r.MeterbusID, err = dev.holdingRegisters.ReadUint16Ptr(0xe035)
+	err = registers.WriteUint16Ptr(0xe034, s.ModbusID)
 	if err != nil {
-		return MiscSettings{}, err
+		return withField("MiscSettings", "ModbusID", err)
+	}
+	err = registers.WriteUint16Ptr(0xe035, s.MeterbusID)
+	if err != nil {
+		return withField("MiscSettings", "MeterbusID", err)
 	}
 
-	return r, nil
+	return nil
 }
 
 func (dev *Dev) ReadPWMSettings() (PWMSettings, error) {
+	return dev.ReadPWMSettingsContext(context.Background())
+}
+
+func (dev *Dev) ReadPWMSettingsContext(ctx context.Context) (PWMSettings, error) {
 	dev.mutex.Lock()
 	defer dev.mutex.Unlock()
 
-	err := dev.requestSetup()
+	err := dev.requestSetup(ctx)
 	if err != nil {
 		return PWMSettings{}, err
 	}
 
 	var r PWMSettings
+	errs := dev.newReadErrors()
+	fields := errs.fields("PWMSettings", dev.holdingRegisters.WithContext(ctx))
 
-	r.ChargeCurrentLimit, err = dev.holdingRegisters.ReadFloat16AsFloat32Ptr(0xe038)
-	if err != nil {
-		return PWMSettings{}, err
-	}
+	r.ChargeCurrentLimit = fields.Float16AsFloat32("ChargeCurrentLimit", 0xe038)
 
-	return r, nil
+	return readResult(errs, r)
 }
 
-func (dev *Dev) ReadStatistics() (Statistics, error) {
+func (dev *Dev) WritePWMSettings(s PWMSettings) error {
+	return dev.WritePWMSettingsContext(context.Background(), s)
+}
+
+func (dev *Dev) WritePWMSettingsContext(ctx context.Context, s PWMSettings) error {
 	dev.mutex.Lock()
 	defer dev.mutex.Unlock()
 
-	err := dev.requestSetup()
+	err := dev.requestSetup(ctx)
 	if err != nil {
-		return Statistics{}, err
+		return err
 	}
 
-	var r Statistics
-	r.Hourmeter, err = dev.holdingRegisters.ReadUint32Ptr(0xe040, WordOrderingLowFirst)
-	if err != nil {
-		return Statistics{}, err
-	}
-	r.AhLoadResettable, err = dev.holdingRegisters.ReadUint32AsFloat32Ptr(0xe042, WordOrderingLowFirst, 10)
-	if err != nil {
-		return Statistics{}, err
-	}
-	r.AhLoadTotal, err = dev.holdingRegisters.ReadUint32AsFloat32Ptr(0xe044, WordOrderingLowFirst, 10)
-	if err != nil {
-		return Statistics{}, err
-	}
-	r.AhChargeResettable, err = dev.holdingRegisters.ReadUint32AsFloat32Ptr(0xe046, WordOrderingLowFirst, 10)
-	if err != nil {
-		return Statistics{}, err
-	}
-	r.AhChargeTotal, err = dev.holdingRegisters.ReadUint32AsFloat32Ptr(0xe048, WordOrderingLowFirst, 10)
-	if err != nil {
-		return Statistics{}, err
-	}
-	r.KWhcResettable, err = dev.holdingRegisters.ReadUint16AsFloat32Ptr(0xe04a, 10)
-	if err != nil {
-		return Statistics{}, err
-	}
-	r.KWhcTotal, err = dev.holdingRegisters.ReadUint16AsFloat32Ptr(0xe04b, 10)
-	if err != nil {
-		return Statistics{}, err
-	}
-	r.BatteryVoltageMinimum, err = dev.holdingRegisters.ReadFloat16AsFloat32Ptr(0xe04c)
-	if err != nil {
-		return Statistics{}, err
-	}
-	r.BatteryVoltageMaximum, err = dev.holdingRegisters.ReadFloat16AsFloat32Ptr(0xe04d)
+	registers := dev.holdingRegisters.WithContext(ctx)
+
+	err = registers.WriteFloat32AsFloat16Ptr(0xe038, s.ChargeCurrentLimit)
 	if err != nil {
-		return Statistics{}, err
+		return withField("PWMSettings", "ChargeCurrentLimit", err)
 	}
-	r.ArrayVoltageMaximum, err = dev.holdingRegisters.ReadFloat16AsFloat32Ptr(0xe04e)
+
+	return nil
+}
+
+func (dev *Dev) ReadStatistics() (Statistics, error) {
+	return dev.ReadStatisticsContext(context.Background())
+}
+
+func (dev *Dev) ReadStatisticsContext(ctx context.Context) (Statistics, error) {
+	dev.mutex.Lock()
+	defer dev.mutex.Unlock()
+
+	err := dev.requestSetup(ctx)
 	if err != nil {
 		return Statistics{}, err
 	}
-	r.TimeSinceLastEqualize, err = dev.holdingRegisters.ReadUint16Ptr(0xe04f)
+
+	registers, err := dev.prefetch(dev.holdingRegisters.WithContext(ctx), 0xe040, 16)
 	if err != nil {
-		return Statistics{}, err
+		return Statistics{}, withGroup("Statistics", err)
 	}
 
-	return r, nil
+	var r Statistics
+	errs := dev.newReadErrors()
+	fields := errs.fields("Statistics", registers)
+	r.Hourmeter = fields.Uint32("Hourmeter", 0xe040, WordOrderingLowFirst)
+	r.AhLoadResettable = fields.Uint32AsFloat32("AhLoadResettable", 0xe042, WordOrderingLowFirst, 10)
+	r.AhLoadTotal = fields.Uint32AsFloat32("AhLoadTotal", 0xe044, WordOrderingLowFirst, 10)
+	r.AhChargeResettable = fields.Uint32AsFloat32("AhChargeResettable", 0xe046, WordOrderingLowFirst, 10)
+	r.AhChargeTotal = fields.Uint32AsFloat32("AhChargeTotal", 0xe048, WordOrderingLowFirst, 10)
+	r.KWhcResettable = fields.Uint16AsFloat32("KWhcResettable", 0xe04a, 10)
+	r.KWhcTotal = fields.Uint16AsFloat32("KWhcTotal", 0xe04b, 10)
+	r.BatteryVoltageMinimum = fields.Float16AsFloat32("BatteryVoltageMinimum", 0xe04c)
+	r.BatteryVoltageMaximum = fields.Float16AsFloat32("BatteryVoltageMaximum", 0xe04d)
+	r.ArrayVoltageMaximum = fields.Float16AsFloat32("ArrayVoltageMaximum", 0xe04e)
+	r.TimeSinceLastEqualize = fields.Uint16("TimeSinceLastEqualize", 0xe04f)
+
+	return readResult(errs, r)
 }
 
+const (
+	loggedDataAddress        = 0x8000
+	loggedDataRecordCount    = 256
+	loggedDataRecordSize     = 16
+	loggedDataRecordsPerRead = 7
+)
+
+type LoggedDataProgressFunc func(recordsRead int, recordCount int)
+
 func (dev *Dev) ReadLoggedData() ([]LoggedDataRecord, error) {
+	return dev.ReadLoggedDataContext(context.Background(), nil)
+}
+
+func (dev *Dev) ReadLoggedDataWithProgress(progress LoggedDataProgressFunc) ([]LoggedDataRecord, error) {
+	return dev.ReadLoggedDataContext(context.Background(), progress)
+}
+
+func (dev *Dev) ReadLoggedDataContext(ctx context.Context, progress LoggedDataProgressFunc) ([]LoggedDataRecord, error) {
 	dev.mutex.Lock()
 	defer dev.mutex.Unlock()
 
-	err := dev.requestSetup()
+	err := dev.requestSetup(ctx)
 	if err != nil {
 		return nil, err
 	}
 
 	var records []LoggedDataRecord
 
-	for i := 0; i < 256; i++ {
-		v, err := dev.mc.ReadRegisters(0x8000+uint16(i*16), 16, modbus.INPUT_REGISTER)
+	for i := 0; i < loggedDataRecordCount; i += loggedDataRecordsPerRead {
+		n := min(loggedDataRecordsPerRead, loggedDataRecordCount-i)
+		v, err := dev.readLoggedDataRecords(ctx, i, n)
 		if err != nil {
-			if errors.Is(err, modbus.ErrIllegalDataAddress) {
+			if errors.Is(err, ErrIllegalDataAddress) {
 				return nil, nil
 			} else {
 				return nil, err
 			}
 		}
-		hourmeter := WordOrderingLowFirst.Uint32(v[0:2])
-		if (hourmeter != 0x00000000) && (hourmeter != 0xffffffff) {
-			records = append(records, LoggedDataRecord{
-				Hourmeter:                  hourmeter,
-				AlarmDaily:                 Alarm(WordOrderingLowFirst.Uint32(v[2:4])).Details(),
-				LoadFaultDaily:             LoadFault(WordOrderingLowFirst.Uint32(v[4:6])).Details(),
-				ArrayFaultDaily:            ArrayFault(WordOrderingLowFirst.Uint32(v[6:8])).Details(),
-				BatteryVoltageMinimumDaily: float16.Frombits(v[8]).Float32(),
-				BatteryVoltageMaximumDaily: float16.Frombits(v[9]).Float32(),
-				AhChargeDaily:              float16.Frombits(v[10]).Float32(),
-				AhLoadDaily:                float16.Frombits(v[11]).Float32(),
-				ArrayVoltageMaximumDaily:   float16.Frombits(v[12]).Float32(),
-				TimeInAbsorptionDaily:      v[13],
-				TimeInEqualizeDaily:        v[14],
-				TimeInFloatDaily:           v[15],
-			})
+		for j := 0; j < n; j++ {
+			record, ok := decodeLoggedDataRecord(v[j*loggedDataRecordSize : (j+1)*loggedDataRecordSize])
+			if ok {
+				records = append(records, record)
+			}
+		}
+		if progress != nil {
+			progress(i+n, loggedDataRecordCount)
 		}
 	}
 
 	return records, nil
 }
 
+func (dev *Dev) readLoggedDataRecords(ctx context.Context, index int, n int) ([]uint16, error) {
+	err := ctx.Err()
+	if err != nil {
+		return nil, err
+	}
+	addr := loggedDataAddress + uint16(index*loggedDataRecordSize)
+	quantity := uint16(n * loggedDataRecordSize)
+	v, err := withContext(dev.transport, ctx).ReadRegisters(addr, quantity, InputRegister)
+	if err == nil {
+		return v, nil
+	}
+	if (n == 1) || !errors.Is(err, ErrIllegalDataAddress) {
+		return nil, &RegisterError{
+			Group:        "LoggedDataRecord",
+			RegisterType: InputRegister,
+			Address:      addr,
+			Quantity:     quantity,
+			Err:          err,
+		}
+	}
+	v = nil
+	for j := 0; j < n; j++ {
+		record, err := dev.readLoggedDataRecords(ctx, index+j, 1)
+		if err != nil {
+			return nil, err
+		}
+		v = append(v, record...)
+	}
+	return v, nil
+}
+
+func decodeLoggedDataRecord(v []uint16) (LoggedDataRecord, bool) {
+	hourmeter := WordOrderingLowFirst.Uint32(v[0:2])
+	if (hourmeter == 0x00000000) || (hourmeter == 0xffffffff) {
+		return LoggedDataRecord{}, false
+	}
+	return LoggedDataRecord{
+		Hourmeter:                  hourmeter,
+		AlarmDaily:                 Alarm(WordOrderingLowFirst.Uint32(v[2:4])).Details(),
+		LoadFaultDaily:             LoadFault(WordOrderingLowFirst.Uint32(v[4:6])).Details(),
+		ArrayFaultDaily:            ArrayFault(WordOrderingLowFirst.Uint32(v[6:8])).Details(),
+		BatteryVoltageMinimumDaily: float16.Frombits(v[8]).Float32(),
+		BatteryVoltageMaximumDaily: float16.Frombits(v[9]).Float32(),
+		AhChargeDaily:              float16.Frombits(v[10]).Float32(),
+		AhLoadDaily:                float16.Frombits(v[11]).Float32(),
+		ArrayVoltageMaximumDaily:   float16.Frombits(v[12]).Float32(),
+		TimeInAbsorptionDaily:      v[13],
+		TimeInEqualizeDaily:        v[14],
+		TimeInFloatDaily:           v[15],
+	}, true
+}
+
+func (dev *Dev) ReadCoil(coil Coil) (bool, error) {
+	return dev.ReadCoilContext(context.Background(), coil)
+}
+
+func (dev *Dev) ReadCoilContext(ctx context.Context, coil Coil) (bool, error) {
+	dev.mutex.Lock()
+	defer dev.mutex.Unlock()
+
+	err := dev.requestSetup(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	v, err := withContext(dev.transport, ctx).ReadCoil(uint16(coil))
+	if err != nil {
+		return false, newRegisterError(CoilRegister, uint16(coil), 1, err)
+	}
+	return v, nil
+}
+
+func (dev *Dev) SetCoil(coil Coil, value bool) error {
+	return dev.SetCoilContext(context.Background(), coil, value)
+}
+
+func (dev *Dev) SetCoilContext(ctx context.Context, coil Coil, value bool) error {
+	dev.mutex.Lock()
+	defer dev.mutex.Unlock()
+
+	err := dev.requestSetup(ctx)
+	if err != nil {
+		return err
+	}
+
+	return newRegisterError(CoilRegister, uint16(coil), 1, withContext(dev.transport, ctx).WriteCoil(uint16(coil), value))
+}
+
 type Registers struct {
-	mc      *modbus.ModbusClient
-	regType modbus.RegType
+	transport Transport
+	regType   RegisterType
+	ctx       context.Context
+	block     *registerBlock
 }
 
-func NewRegisters(mc *modbus.ModbusClient, regType modbus.RegType) *Registers {
+type registerBlock struct {
+	addr   uint16
+	values []uint16
+}
+
+func (b *registerBlock) get(addr uint16, quantity uint16) ([]uint16, bool) {
+	if b == nil {
+		return nil, false
+	}
+	if (addr < b.addr) || (int(addr)+int(quantity) > int(b.addr)+len(b.values)) {
+		return nil, false
+	}
+	offset := addr - b.addr
+	return b.values[offset : offset+quantity], true
+}
+
+func NewRegisters(transport Transport, regType RegisterType) *Registers {
 	return &Registers{
-		mc:      mc,
-		regType: regType,
+		transport: transport,
+		regType:   regType,
+	}
+}
+
+func (r *Registers) WithContext(ctx context.Context) *Registers {
+	r2 := *r
+	r2.transport = withContext(r.transport, ctx)
+	r2.ctx = ctx
+	return &r2
+}
+
+func (r *Registers) checkContext() error {
+	if r.ctx == nil {
+		return nil
 	}
+	return r.ctx.Err()
+}
+
+func (r *Registers) Prefetch(addr uint16, quantity uint16) (*Registers, error) {
+	err := r.checkContext()
+	if err != nil {
+		return nil, err
+	}
+	v, err := r.transport.ReadRegisters(addr, quantity, r.regType)
+	if err != nil {
+		if errors.Is(err, ErrIllegalDataAddress) {
+			return r, nil
+		} else {
+			return nil, newRegisterError(r.regType, addr, quantity, err)
+		}
+	}
+	return &Registers{
+		transport: r.transport,
+		regType:   r.regType,
+		ctx:       r.ctx,
+		block: &registerBlock{
+			addr:   addr,
+			values: v,
+		},
+	}, nil
+}
+
+func (r *Registers) readRegister(addr uint16) (uint16, error) {
+	if v, ok := r.block.get(addr, 1); ok {
+		return v[0], nil
+	}
+	err := r.checkContext()
+	if err != nil {
+		return 0, err
+	}
+	v, err := r.transport.ReadRegister(addr, r.regType)
+	if err != nil {
+		return 0, newRegisterError(r.regType, addr, 1, err)
+	}
+	return v, nil
+}
+
+func (r *Registers) readRegisters(addr uint16, quantity uint16) ([]uint16, error) {
+	if v, ok := r.block.get(addr, quantity); ok {
+		return v, nil
+	}
+	err := r.checkContext()
+	if err != nil {
+		return nil, err
+	}
+	v, err := r.transport.ReadRegisters(addr, quantity, r.regType)
+	if err != nil {
+		return nil, newRegisterError(r.regType, addr, quantity, err)
+	}
+	return v, nil
 }
 
 func (r *Registers) ReadUint16(addr uint16) (uint16, error) {
-	return r.mc.ReadRegister(addr, r.regType)
+	return r.readRegister(addr)
 }
 
 func (r *Registers) ReadUint16Ptr(addr uint16) (*uint16, error) {
 	v, err := r.ReadUint16(addr)
 	if err != nil {
-		if errors.Is(err, modbus.ErrIllegalDataAddress) {
+		if errors.Is(err, ErrIllegalDataAddress) {
 			return nil, nil
 		} else {
 			return nil, err
@@ -648,7 +894,7 @@ func (r *Registers) ReadUint16Ptr(addr uint16) (*uint16, error) {
 }
 
 func (r *Registers) ReadFloat16(addr uint16) (float16.Float16, error) {
-	v, err := r.mc.ReadRegister(addr, r.regType)
+	v, err := r.readRegister(addr)
 	if err != nil {
 		return 0, err
 	}
@@ -666,7 +912,7 @@ func (r *Registers) ReadFloat16AsFloat32(addr uint16) (float32, error) {
 func (r *Registers) ReadFloat16AsFloat32Ptr(addr uint16) (*float32, error) {
 	v, err := r.ReadFloat16AsFloat32(addr)
 	if err != nil {
-		if errors.Is(err, modbus.ErrIllegalDataAddress) {
+		if errors.Is(err, ErrIllegalDataAddress) {
 			return nil, nil
 		} else {
 			return nil, err
@@ -686,7 +932,7 @@ func (r *Registers) ReadUint16AsFloat32(addr uint16, divisor float32) (float32,
 func (r *Registers) ReadUint16AsFloat32Ptr(addr uint16, divisor float32) (*float32, error) {
 	v, err := r.ReadUint16AsFloat32(addr, divisor)
 	if err != nil {
-		if errors.Is(err, modbus.ErrIllegalDataAddress) {
+		if errors.Is(err, ErrIllegalDataAddress) {
 			return nil, nil
 		} else {
 			return nil, err
@@ -706,7 +952,7 @@ func (r *Registers) ReadUint16AsInt16(addr uint16) (int16, error) {
 func (r *Registers) ReadUint16AsInt16Ptr(addr uint16) (*int16, error) {
 	v, err := r.ReadUint16AsInt16(addr)
 	if err != nil {
-		if errors.Is(err, modbus.ErrIllegalDataAddress) {
+		if errors.Is(err, ErrIllegalDataAddress) {
 			return nil, nil
 		} else {
 			return nil, err
@@ -716,7 +962,7 @@ func (r *Registers) ReadUint16AsInt16Ptr(addr uint16) (*int16, error) {
 }
 
 func (r *Registers) ReadUint32(addr uint16, wordOrdering WordOrdering) (uint32, error) {
-	b, err := r.mc.ReadRegisters(addr, 2, r.regType)
+	b, err := r.readRegisters(addr, 2)
 	if err != nil {
 		return 0, err
 	}
@@ -726,7 +972,7 @@ func (r *Registers) ReadUint32(addr uint16, wordOrdering WordOrdering) (uint32,
 func (r *Registers) ReadUint32Ptr(addr uint16, wordOrdering WordOrdering) (*uint32, error) {
 	v, err := r.ReadUint32(addr, wordOrdering)
 	if err != nil {
-		if errors.Is(err, modbus.ErrIllegalDataAddress) {
+		if errors.Is(err, ErrIllegalDataAddress) {
 			return nil, nil
 		} else {
 			return nil, err
@@ -746,7 +992,7 @@ func (r *Registers) ReadUint32AsFloat32(addr uint16, wordOrdering WordOrdering,
 func (r *Registers) ReadUint32AsFloat32Ptr(addr uint16, wordOrdering WordOrdering, divisor float32) (*float32, error) {
 	v, err := r.ReadUint32AsFloat32(addr, wordOrdering, divisor)
 	if err != nil {
-		if errors.Is(err, modbus.ErrIllegalDataAddress) {
+		if errors.Is(err, ErrIllegalDataAddress) {
 			return nil, nil
 		} else {
 			return nil, err
@@ -755,6 +1001,64 @@ func (r *Registers) ReadUint32AsFloat32Ptr(addr uint16, wordOrdering WordOrderin
 	return &v, nil
 }
 
+func (r *Registers) WriteUint16(addr uint16, v uint16) error {
+	err := r.checkContext()
+	if err != nil {
+		return err
+	}
+	err = r.transport.WriteRegister(addr, v)
+	if err != nil {
+		return newRegisterError(r.regType, addr, 1, err)
+	}
+	readBack, err := r.transport.ReadRegister(addr, r.regType)
+	if err != nil {
+		return newRegisterError(r.regType, addr, 1, err)
+	}
+	if readBack != v {
+		return newRegisterError(r.regType, addr, 1, fmt.Errorf("%w: wrote 0x%04x, read back 0x%04x", ErrWriteVerificationFailed, v, readBack))
+	}
+	return nil
+}
+
+func (r *Registers) WriteUint16Ptr(addr uint16, v *uint16) error {
+	if v == nil {
+		return nil
+	}
+	return r.WriteUint16(addr, *v)
+}
+
+func (r *Registers) WriteFloat16(addr uint16, v float16.Float16) error {
+	return r.WriteUint16(addr, v.Bits())
+}
+
+// WriteFloat32AsFloat16 writes v as a float16. NaN, infinities and values beyond the float16 range
+// are rejected with ErrNotRepresentable without writing.
+func (r *Registers) WriteFloat32AsFloat16(addr uint16, v float32) error {
+	f := float16.Fromfloat32(v)
+	if !f.IsFinite() {
+		return newRegisterError(r.regType, addr, 1, fmt.Errorf("%w: %v", ErrNotRepresentable, v))
+	}
+	return r.WriteFloat16(addr, f)
+}
+
+func (r *Registers) WriteFloat32AsFloat16Ptr(addr uint16, v *float32) error {
+	if v == nil {
+		return nil
+	}
+	return r.WriteFloat32AsFloat16(addr, *v)
+}
+
+func (r *Registers) WriteInt16AsUint16(addr uint16, v int16) error {
+	return r.WriteUint16(addr, uint16(v))
+}
+
+func (r *Registers) WriteInt16AsUint16Ptr(addr uint16, v *int16) error {
+	if v == nil {
+		return nil
+	}
+	return r.WriteInt16AsUint16(addr, *v)
+}
+
 type WordOrdering int
 
 const (
@@ -0,0 +1,49 @@
+package prostar_pwm
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestWriteFloat32AsFloat16(t *testing.T) {
+	tests := []struct {
+		name string
+		v    float32
+	}{
+		{"NaN", float32(math.NaN())},
+		{"+Inf", float32(math.Inf(1))},
+		{"-Inf", float32(math.Inf(-1))},
+		{"above range", 70000},
+		{"below range", -70000},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			transport := newFakeTransport()
+			registers := NewRegisters(transport, HoldingRegister)
+			err := registers.WriteFloat32AsFloat16(0xe000, test.v)
+			if !errors.Is(err, ErrNotRepresentable) {
+				t.Fatalf("err = %v, want %v", err, ErrNotRepresentable)
+			}
+			var registerError *RegisterError
+			if !errors.As(err, &registerError) || (registerError.Address != 0xe000) {
+				t.Errorf("err = %v, want a RegisterError for 0xe000", err)
+			}
+			if transport.writes != 0 {
+				t.Errorf("%d writes, want none", transport.writes)
+			}
+		})
+	}
+
+	t.Run("in range", func(t *testing.T) {
+		transport := newFakeTransport()
+		registers := NewRegisters(transport, HoldingRegister)
+		err := registers.WriteFloat32AsFloat16(0xe000, 14.4)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if transport.registers[0xe000] != 0x4b33 {
+			t.Errorf("wrote 0x%04x, want 0x4b33", transport.registers[0xe000])
+		}
+	})
+}
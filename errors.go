@@ -0,0 +1,137 @@
+package prostar_pwm
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/simonvetter/modbus"
+)
+
+var (
+	// ErrTimeout matches a RegisterError for a request the device did not answer in time, including a
+	// gateway reporting that the device failed to respond.
+	ErrTimeout = errors.New("timeout")
+	// ErrException matches a RegisterError for a request the device answered with a Modbus exception.
+	ErrException = errors.New("modbus exception")
+	// ErrBadCRC is returned by a Transport when a response fails the CRC check.
+	ErrBadCRC = modbus.ErrBadCRC
+	// ErrProtocol is returned by a Transport when a response is malformed.
+	ErrProtocol = modbus.ErrProtocolError
+	// ErrShortFrame is returned by a Transport when a response is truncated.
+	ErrShortFrame = modbus.ErrShortFrame
+)
+
+var modbusExceptions = []error{
+	modbus.ErrIllegalFunction,
+	modbus.ErrIllegalDataAddress,
+	modbus.ErrIllegalDataValue,
+	modbus.ErrServerDeviceFailure,
+	modbus.ErrAcknowledge,
+	modbus.ErrServerDeviceBusy,
+	modbus.ErrMemoryParityError,
+	modbus.ErrGWPathUnavailable,
+	modbus.ErrGWTargetFailedToRespond,
+}
+
+// RegisterError describes a failed register or coil access. Group and Field name the Go struct and
+// field being read or written; they are empty if the access was not for a single struct or field.
+type RegisterError struct {
+	Group        string
+	Field        string
+	RegisterType RegisterType
+	Address      uint16
+	Quantity     uint16
+	Err          error
+}
+
+func (e *RegisterError) Error() string {
+	var name string
+	switch {
+	case (e.Group != "") && (e.Field != ""):
+		name = e.Group + "." + e.Field + ": "
+	case e.Group != "":
+		name = e.Group + ": "
+	case e.Field != "":
+		name = e.Field + ": "
+	}
+	location := fmt.Sprintf("%s 0x%04x", e.RegisterType, e.Address)
+	if e.Quantity > 1 {
+		location = fmt.Sprintf("%s 0x%04x-0x%04x", e.RegisterType, e.Address, e.Address+e.Quantity-1)
+	}
+	return fmt.Sprintf("%s%s: %v", name, location, e.Err)
+}
+
+func (e *RegisterError) Unwrap() error {
+	return e.Err
+}
+
+func (e *RegisterError) Is(target error) bool {
+	switch target {
+	case ErrTimeout:
+		return isTimeout(e.Err)
+	case ErrException:
+		return isException(e.Err)
+	}
+	return false
+}
+
+func isTimeout(err error) bool {
+	return errors.Is(err, modbus.ErrRequestTimedOut) ||
+		errors.Is(err, modbus.ErrGWTargetFailedToRespond) ||
+		errors.Is(err, os.ErrDeadlineExceeded)
+}
+
+func isException(err error) bool {
+	for _, exception := range modbusExceptions {
+		if errors.Is(err, exception) {
+			return true
+		}
+	}
+	return false
+}
+
+// errorMatches is errors.Is, extended to the ErrTimeout and ErrException classes for errors that are
+// not wrapped in a RegisterError yet.
+func errorMatches(err error, target error) bool {
+	switch target {
+	case ErrTimeout:
+		return isTimeout(err)
+	case ErrException:
+		return isException(err)
+	}
+	return errors.Is(err, target)
+}
+
+func newRegisterError(regType RegisterType, addr uint16, quantity uint16, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &RegisterError{
+		RegisterType: regType,
+		Address:      addr,
+		Quantity:     quantity,
+		Err:          err,
+	}
+}
+
+// withField sets the group and field of a RegisterError, leaving other errors unchanged.
+func withField(group string, field string, err error) error {
+	registerError, ok := err.(*RegisterError)
+	if !ok {
+		return err
+	}
+	e := *registerError
+	if e.Group == "" {
+		e.Group = group
+	}
+	if e.Field == "" {
+		e.Field = field
+	}
+	return &e
+}
+
+// withGroup sets the group of a RegisterError, leaving other errors unchanged.
+func withGroup(group string, err error) error {
+	return withField(group, "", err)
+}
@@ -0,0 +1,370 @@
+package prostar_pwm
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/goburrow/serial"
+	"github.com/simonvetter/modbus"
+)
+
+var (
+	// ErrPDUNotSupported is returned by ExecutePDU when the transport cannot send raw request PDUs.
+	ErrPDUNotSupported = errors.New("raw PDUs not supported by transport")
+)
+
+// PDUTransport is implemented by a Transport that can send request PDUs the other Transport methods
+// do not cover, such as Read Device Identification (function 0x2B/0x0E). ExecutePDU sends the
+// function code and data to the current unit ID and returns the data of the response, without the
+// function code. Exception responses are returned as errors. A Transport that wraps another should
+// implement it and pass the request on, returning ErrPDUNotSupported if the other does not.
+type PDUTransport interface {
+	ExecutePDU(functionCode uint8, data []byte) ([]byte, error)
+}
+
+// executePDU sends a request PDU if transport is a PDUTransport.
+func executePDU(transport Transport, functionCode uint8, data []byte) ([]byte, error) {
+	pduTransport, ok := transport.(PDUTransport)
+	if !ok {
+		return nil, ErrPDUNotSupported
+	}
+	return pduTransport.ExecutePDU(functionCode, data)
+}
+
+// ExecutePDU is only supported by the transports of a Bus created by NewModbusBus. The modbus client
+// cannot send arbitrary PDUs, so its connection is closed for the request, which is sent over a
+// connection of its own with the same configuration, and reopened afterwards.
+func (t *ModbusClientTransport) ExecutePDU(functionCode uint8, data []byte) ([]byte, error) {
+	if t.config == nil {
+		return nil, ErrPDUNotSupported
+	}
+	err := t.mc.Close()
+	if err != nil {
+		return nil, err
+	}
+	res, err := func() ([]byte, error) {
+		conn, err := dialPDU(*t.config)
+		if err != nil {
+			return nil, err
+		}
+		defer func() {
+			_ = conn.Close()
+		}()
+		return conn.execute(t.unitId, functionCode, data)
+	}()
+	openErr := t.mc.Open()
+	if err != nil {
+		return nil, err
+	}
+	if openErr != nil {
+		return nil, openErr
+	}
+	return res, nil
+}
+
+// pduConn is a minimal Modbus client for raw request PDUs, over Modbus TCP or RTU framing.
+type pduConn struct {
+	conn          io.ReadWriteCloser
+	rtu           bool
+	timeout       time.Duration
+	transactionId uint16
+}
+
+// dialPDU opens a connection for the URL schemes supported by the modbus client, applying the same
+// defaults.
+func dialPDU(config modbus.ClientConfiguration) (*pduConn, error) {
+	scheme, addr, ok := strings.Cut(config.URL, "://")
+	if !ok {
+		return nil, fmt.Errorf("invalid URL: %s", config.URL)
+	}
+	timeout := config.Timeout
+	if timeout == 0 {
+		timeout = 1 * time.Second
+	}
+
+	switch scheme {
+	case "tcp":
+		conn, err := net.DialTimeout("tcp", addr, timeout)
+		if err != nil {
+			return nil, err
+		}
+		return &pduConn{conn: conn, timeout: timeout}, nil
+	case "rtuovertcp", "rtuoverudp":
+		network := "tcp"
+		if scheme == "rtuoverudp" {
+			network = "udp"
+		}
+		conn, err := net.DialTimeout(network, addr, timeout)
+		if err != nil {
+			return nil, err
+		}
+		return &pduConn{conn: conn, rtu: true, timeout: timeout}, nil
+	case "rtu":
+		if config.Timeout == 0 {
+			timeout = 300 * time.Millisecond
+		}
+		speed := config.Speed
+		if speed == 0 {
+			speed = 19200
+		}
+		dataBits := config.DataBits
+		if dataBits == 0 {
+			dataBits = 8
+		}
+		parity := "N"
+		switch config.Parity {
+		case modbus.PARITY_EVEN:
+			parity = "E"
+		case modbus.PARITY_ODD:
+			parity = "O"
+		}
+		stopBits := config.StopBits
+		if stopBits == 0 {
+			stopBits = 1
+			if config.Parity == modbus.PARITY_NONE {
+				stopBits = 2
+			}
+		}
+		port, err := serial.Open(&serial.Config{
+			Address:  addr,
+			BaudRate: int(speed),
+			DataBits: int(dataBits),
+			Parity:   parity,
+			StopBits: int(stopBits),
+			Timeout:  10 * time.Millisecond,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return &pduConn{conn: port, rtu: true, timeout: timeout}, nil
+	}
+	return nil, fmt.Errorf("unsupported URL: %s", config.URL)
+}
+
+func (c *pduConn) Close() error {
+	return c.conn.Close()
+}
+
+func (c *pduConn) execute(unitId uint8, functionCode uint8, data []byte) ([]byte, error) {
+	deadline := time.Now().Add(c.timeout)
+	var res []byte
+	var err error
+	if c.rtu {
+		res, err = c.executeRTU(unitId, functionCode, data, deadline)
+	} else {
+		res, err = c.executeTCP(unitId, functionCode, data, deadline)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parseResponsePDU(functionCode, res)
+}
+
+func (c *pduConn) executeTCP(unitId uint8, functionCode uint8, data []byte, deadline time.Time) ([]byte, error) {
+	c.transactionId++
+	req := binary.BigEndian.AppendUint16(nil, c.transactionId)
+	req = binary.BigEndian.AppendUint16(req, 0)
+	req = binary.BigEndian.AppendUint16(req, uint16(2+len(data)))
+	req = append(req, unitId, functionCode)
+	req = append(req, data...)
+	_, err := c.conn.Write(req)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, 7)
+	err = c.readFull(header, deadline)
+	if err != nil {
+		return nil, err
+	}
+	length := int(binary.BigEndian.Uint16(header[4:6]))
+	if length < 2 {
+		return nil, modbus.ErrProtocolError
+	}
+	res := make([]byte, length-1)
+	err = c.readFull(res, deadline)
+	if err != nil {
+		return nil, err
+	}
+	if binary.BigEndian.Uint16(header[0:2]) != c.transactionId {
+		return nil, modbus.ErrBadTransactionId
+	}
+	if binary.BigEndian.Uint16(header[2:4]) != 0 {
+		return nil, modbus.ErrUnknownProtocolId
+	}
+	if header[6] != unitId {
+		return nil, modbus.ErrBadUnitId
+	}
+	return res, nil
+}
+
+func (c *pduConn) executeRTU(unitId uint8, functionCode uint8, data []byte, deadline time.Time) ([]byte, error) {
+	req := append([]byte{unitId, functionCode}, data...)
+	req = binary.LittleEndian.AppendUint16(req, crc16(req))
+	_, err := c.conn.Write(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf []byte
+	chunk := make([]byte, 256)
+	for {
+		length, err := rtuResponseLength(buf)
+		if err != nil {
+			return nil, err
+		}
+		if (length > 0) && (len(buf) >= length) {
+			buf = buf[:length]
+			break
+		}
+		n, err := c.read(chunk, deadline)
+		if err != nil {
+			return nil, err
+		}
+		buf = append(buf, chunk[:n]...)
+	}
+	if crc16(buf[:len(buf)-2]) != binary.LittleEndian.Uint16(buf[len(buf)-2:]) {
+		return nil, modbus.ErrBadCRC
+	}
+	if buf[0] != unitId {
+		return nil, modbus.ErrBadUnitId
+	}
+	return buf[1 : len(buf)-2], nil
+}
+
+// rtuResponseLength returns the length of the response frame at the start of buf, including the CRC,
+// or 0 if more bytes are needed to tell.
+func rtuResponseLength(buf []byte) (int, error) {
+	if len(buf) < 3 {
+		return 0, nil
+	}
+	functionCode := buf[1]
+	if functionCode&0x80 != 0 {
+		return 5, nil
+	}
+	switch functionCode {
+	case 0x01, 0x02, 0x03, 0x04:
+		return 5 + int(buf[2]), nil
+	case 0x05, 0x06, 0x0f, 0x10:
+		return 8, nil
+	case fcEncapsulatedInterfaceTransport:
+		if buf[2] != meiReadDeviceIdentification {
+			break
+		}
+		// unit ID, function code, MEI type, read device ID code, conformity level, more follows,
+		// next object ID and number of objects, followed by the objects
+		length := 8
+		if len(buf) < length {
+			return 0, nil
+		}
+		for i := 0; i < int(buf[7]); i++ {
+			if len(buf) < length+2 {
+				return 0, nil
+			}
+			length += 2 + int(buf[length+1])
+		}
+		return length + 2, nil
+	}
+	return 0, fmt.Errorf("%w: function code 0x%02x", modbus.ErrProtocolError, functionCode)
+}
+
+// parseResponsePDU returns the data of a response PDU, or the error of an exception response.
+func parseResponsePDU(functionCode uint8, res []byte) ([]byte, error) {
+	if len(res) < 1 {
+		return nil, modbus.ErrShortFrame
+	}
+	switch res[0] {
+	case functionCode:
+		return res[1:], nil
+	case functionCode | 0x80:
+		if len(res) < 2 {
+			return nil, modbus.ErrShortFrame
+		}
+		return nil, exceptionError(res[1])
+	}
+	return nil, modbus.ErrProtocolError
+}
+
+func exceptionError(code uint8) error {
+	switch code {
+	case 0x01:
+		return modbus.ErrIllegalFunction
+	case 0x02:
+		return modbus.ErrIllegalDataAddress
+	case 0x03:
+		return modbus.ErrIllegalDataValue
+	case 0x04:
+		return modbus.ErrServerDeviceFailure
+	case 0x05:
+		return modbus.ErrAcknowledge
+	case 0x06:
+		return modbus.ErrServerDeviceBusy
+	case 0x08:
+		return modbus.ErrMemoryParityError
+	case 0x0a:
+		return modbus.ErrGWPathUnavailable
+	case 0x0b:
+		return modbus.ErrGWTargetFailedToRespond
+	}
+	return fmt.Errorf("unknown exception code 0x%02x", code)
+}
+
+func (c *pduConn) readFull(b []byte, deadline time.Time) error {
+	for len(b) > 0 {
+		n, err := c.read(b, deadline)
+		if err != nil {
+			return err
+		}
+		b = b[n:]
+	}
+	return nil
+}
+
+// read reads at least one byte, or fails with modbus.ErrRequestTimedOut after deadline.
+func (c *pduConn) read(b []byte, deadline time.Time) (int, error) {
+	if conn, ok := c.conn.(net.Conn); ok {
+		err := conn.SetReadDeadline(deadline)
+		if err != nil {
+			return 0, err
+		}
+		n, err := conn.Read(b)
+		if errors.Is(err, io.EOF) && (n == 0) {
+			return 0, io.ErrUnexpectedEOF
+		}
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			return n, modbus.ErrRequestTimedOut
+		}
+		return n, err
+	}
+	// the serial port times out after a short while, so that the deadline can be checked
+	for {
+		n, err := c.conn.Read(b)
+		if (n > 0) || ((err != nil) && !errors.Is(err, serial.ErrTimeout)) {
+			return n, err
+		}
+		if time.Now().After(deadline) {
+			return 0, modbus.ErrRequestTimedOut
+		}
+	}
+}
+
+func crc16(b []byte) uint16 {
+	crc := uint16(0xffff)
+	for _, c := range b {
+		crc ^= uint16(c)
+		for i := 0; i < 8; i++ {
+			if crc&1 == 1 {
+				crc = (crc >> 1) ^ 0xa001
+			} else {
+				crc >>= 1
+			}
+		}
+	}
+	return crc
+}
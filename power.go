@@ -0,0 +1,85 @@
+package prostar_pwm
+
+const (
+	// below this array power, the charge efficiency estimate is dominated by measurement noise
+	minEfficiencyArrayPower = 1
+)
+
+// PowerData holds values derived from FilteredADCData. Power is in W. BatteryPower is positive while
+// the battery is charging and negative while it is discharging.
+type PowerData struct {
+	ArrayPower       *float32
+	LoadPower        *float32
+	BatteryPower     *float32
+	ChargeEfficiency *float32
+}
+
+// ArrayPower returns the power drawn from the array, or nil if it cannot be computed.
+func (d FilteredADCData) ArrayPower() *float32 {
+	return multiply(d.ArrayVoltage, d.ArrayCurrent)
+}
+
+// LoadPower returns the power delivered to the load, or nil if it cannot be computed.
+func (d FilteredADCData) LoadPower() *float32 {
+	return multiply(d.LoadVoltage, d.LoadCurrent)
+}
+
+// BatteryPower returns the net battery power from the slow-filtered battery voltage and current:
+// positive while charging, negative while discharging. It is nil if it cannot be computed.
+func (d FilteredADCData) BatteryPower() *float32 {
+	return multiply(d.BatteryVoltage, d.BatteryCurrent)
+}
+
+// ChargeEfficiency estimates the fraction of the array power that reaches the battery and the load.
+// It is nil at night or with too little array power for a meaningful estimate. As the battery values
+// are slow-filtered (60s), the estimate is only reliable under steady conditions.
+func (d FilteredADCData) ChargeEfficiency() *float32 {
+	arrayPower := d.ArrayPower()
+	batteryPower := d.BatteryPower()
+	loadPower := d.LoadPower()
+	if (arrayPower == nil) || (batteryPower == nil) || (loadPower == nil) || (*arrayPower < minEfficiencyArrayPower) {
+		return nil
+	}
+	v := (*batteryPower + *loadPower) / *arrayPower
+	return &v
+}
+
+func (d FilteredADCData) PowerData() PowerData {
+	return PowerData{
+		ArrayPower:       d.ArrayPower(),
+		LoadPower:        d.LoadPower(),
+		BatteryPower:     d.BatteryPower(),
+		ChargeEfficiency: d.ChargeEfficiency(),
+	}
+}
+
+// RegulationVoltageTarget returns RegulationVoltageAt25C compensated for the battery temperature t:
+// the temperature is clamped to MinBatteryTempCompensationLimit and MaxBatteryTempCompensationLimit,
+// the voltage lowered by TemperatureCompensationCoefficient per ºC above 25ºC (raised below it), and
+// the result capped at MaximumChargeVoltageReference unless that is 0. It returns nil if the
+// regulation voltage, coefficient or temperature is not available.
+func (s ChargeSettings) RegulationVoltageTarget(t TemperatureData) *float32 {
+	if (s.RegulationVoltageAt25C == nil) || (s.TemperatureCompensationCoefficient == nil) || (t.Battery == nil) {
+		return nil
+	}
+	temperature := *t.Battery
+	if s.MaxBatteryTempCompensationLimit != nil {
+		temperature = min(temperature, float32(*s.MaxBatteryTempCompensationLimit))
+	}
+	if s.MinBatteryTempCompensationLimit != nil {
+		temperature = max(temperature, float32(*s.MinBatteryTempCompensationLimit))
+	}
+	v := *s.RegulationVoltageAt25C - *s.TemperatureCompensationCoefficient*(temperature-25)
+	if (s.MaximumChargeVoltageReference != nil) && (*s.MaximumChargeVoltageReference > 0) {
+		v = min(v, *s.MaximumChargeVoltageReference)
+	}
+	return &v
+}
+
+func multiply(a *float32, b *float32) *float32 {
+	if (a == nil) || (b == nil) {
+		return nil
+	}
+	v := *a * *b
+	return &v
+}
@@ -0,0 +1,138 @@
+package prostar_pwm
+
+// Preset is a battery charging profile. Fields left nil are not changed when the preset is applied.
+// A SystemVoltage of 0 means the preset does not depend on the system voltage.
+//
+// The built-in presets set the charge and equalization voltages and times, the temperature
+// compensation, HVD/HVR and the load LVD/LVR and HVD/HVR. They leave the settings that the
+// operator's manual does not give per battery type nil: absorption extension, float cancel and exit,
+// the equalization time above regulation, the charge voltage limits, the temperature compensation
+// limits, and the LVD current compensation and warning timeout.
+type Preset struct {
+	Name           string
+	Description    string
+	SystemVoltage  int
+	ChargeSettings ChargeSettings
+	LoadSettings   LoadSettings
+}
+
+func (p Preset) Settings() Settings {
+	return Settings{
+		ChargeSettings: p.ChargeSettings,
+		LoadSettings:   p.LoadSettings,
+	}
+}
+
+// presetValues holds the values of a built-in preset for a 12 V system. Voltages are doubled for
+// 24 V; times are in seconds.
+type presetValues struct {
+	regulation     float32
+	float          float32
+	absorptionTime uint16
+	equalize       float32
+	daysBetweenEQ  uint16
+	equalizeTime   uint16
+	tempComp       float32
+	hvd            float32
+	hvr            float32
+	lvd            float32
+	lvr            float32
+	loadHVD        float32
+	loadHVR        float32
+}
+
+// The lead-acid charging values are the ProStar Gen3 standard battery types from the charging
+// settings table of the operator's manual (DIP switches 4-6): gel is type 1, AGM type 2 ("Sealed")
+// and flooded type 4, with the manual's temperature compensation of -30 mV/ºC per 12 V. Battery types
+// without equalization get no equalization cycles, and equalize at the regulation voltage if one is
+// started by hand. LVD and LVR are the manual's default load disconnect settings (DIP switches 2-3
+// off). The manual gives no battery-type specific HVD and HVR; the lead-acid presets disconnect at
+// 15.5 V, above every charge voltage in the table.
+//
+// The LiFePO4 values are the common cell manufacturer ratings per cell: 3.55 V absorption for 30
+// minutes, 3.40 V float, 3.65 V maximum (HVD, reconnecting at the float voltage), 3.00 V discharged
+// (LVD) and 3.25 V to reconnect the load, without temperature compensation or equalization. Check them against the battery manual.
+//
+// See Preset for the settings that are left as they are.
+var (
+	presetFlooded = presetValues{
+		regulation: 14.4, float: 13.7, absorptionTime: 180 * 60,
+		equalize: 15.1, daysBetweenEQ: 28, equalizeTime: 120 * 60,
+		tempComp: 0.03, hvd: 15.5, hvr: 14.5,
+		lvd: 11.5, lvr: 12.6, loadHVD: 15.5, loadHVR: 14.5,
+	}
+	presetGel = presetValues{
+		regulation: 14.0, float: 13.7, absorptionTime: 150 * 60,
+		equalize: 14.0, daysBetweenEQ: 0, equalizeTime: 0,
+		tempComp: 0.03, hvd: 15.5, hvr: 14.5,
+		lvd: 11.5, lvr: 12.6, loadHVD: 15.5, loadHVR: 14.5,
+	}
+	presetAGM = presetValues{
+		regulation: 14.15, float: 13.7, absorptionTime: 150 * 60,
+		equalize: 14.4, daysBetweenEQ: 28, equalizeTime: 60 * 60,
+		tempComp: 0.03, hvd: 15.5, hvr: 14.5,
+		lvd: 11.5, lvr: 12.6, loadHVD: 15.5, loadHVR: 14.5,
+	}
+	presetLiFePO4 = presetValues{
+		regulation: 14.2, float: 13.6, absorptionTime: 30 * 60,
+		equalize: 14.2, daysBetweenEQ: 0, equalizeTime: 0,
+		tempComp: 0, hvd: 14.6, hvr: 13.6,
+		lvd: 12.0, lvr: 13.0, loadHVD: 14.6, loadHVR: 13.6,
+	}
+)
+
+func newPreset(name string, description string, systemVoltage int, v presetValues) Preset {
+	scale := float32(systemVoltage) / 12
+	voltage := func(v float32) *float32 {
+		scaled := v * scale
+		return &scaled
+	}
+	return Preset{
+		Name:          name,
+		Description:   description,
+		SystemVoltage: systemVoltage,
+		ChargeSettings: ChargeSettings{
+			RegulationVoltageAt25C:             voltage(v.regulation),
+			FloatVoltageAt25C:                  voltage(v.float),
+			TimeBeforeEnteringFloat:            uint16Ptr(v.absorptionTime),
+			EqualizeVoltageAt25C:               voltage(v.equalize),
+			DaysBetweenEQCycles:                uint16Ptr(v.daysBetweenEQ),
+			EqualizeTimeLimitAtEVEq:            uint16Ptr(v.equalizeTime),
+			TemperatureCompensationCoefficient: voltage(v.tempComp),
+			HighVoltageDisconnectAt25C:         voltage(v.hvd),
+			HighVoltageReconnect:               voltage(v.hvr),
+		},
+		LoadSettings: LoadSettings{
+			LowVoltageDisconnect:      voltage(v.lvd),
+			LowVoltageReconnect:       voltage(v.lvr),
+			LoadHighVoltageDisconnect: voltage(v.loadHVD),
+			LoadHighVoltageReconnect:  voltage(v.loadHVR),
+		},
+	}
+}
+
+func uint16Ptr(v uint16) *uint16 {
+	return &v
+}
+
+func BuiltinPresets() []Preset {
+	return []Preset{
+		newPreset("flooded-12v", "Flooded lead-acid, 12 V", 12, presetFlooded),
+		newPreset("flooded-24v", "Flooded lead-acid, 24 V", 24, presetFlooded),
+		newPreset("gel-12v", "Sealed gel, 12 V", 12, presetGel),
+		newPreset("gel-24v", "Sealed gel, 24 V", 24, presetGel),
+		newPreset("agm-12v", "Sealed AGM, 12 V", 12, presetAGM),
+		newPreset("agm-24v", "Sealed AGM, 24 V", 24, presetAGM),
+		newPreset("lifepo4-12v", "LiFePO4 (4S), 12 V", 12, presetLiFePO4),
+		newPreset("lifepo4-24v", "LiFePO4 (8S), 24 V", 24, presetLiFePO4),
+	}
+}
+
+func FindPreset(presets []Preset, name string) (Preset, bool) {
+	for _, preset := range presets {
+		if preset.Name == name {
+			return preset, true
+		}
+	}
+	return Preset{}, false
+}
@@ -0,0 +1,31 @@
+package prostar_pwm
+
+import (
+	"testing"
+)
+
+// TestBuiltinPresets checks that every built-in preset is valid on its own and when applied over
+// any other built-in preset for the same system voltage.
+func TestBuiltinPresets(t *testing.T) {
+	presets := BuiltinPresets()
+	for _, preset := range presets {
+		t.Run(preset.Name, func(t *testing.T) {
+			if (preset.LoadSettings.LowVoltageDisconnect == nil) || (preset.LoadSettings.LowVoltageReconnect == nil) {
+				t.Errorf("LVD/LVR not set")
+			}
+			err := ValidateSettings(preset.Settings(), Settings{}, preset.SystemVoltage, MaxChargeCurrentPS15)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, previous := range presets {
+				if previous.SystemVoltage != preset.SystemVoltage {
+					continue
+				}
+				err = ValidateSettings(preset.Settings(), previous.Settings(), preset.SystemVoltage, MaxChargeCurrentPS15)
+				if err != nil {
+					t.Errorf("over %s: %v", previous.Name, err)
+				}
+			}
+		})
+	}
+}
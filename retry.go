@@ -0,0 +1,233 @@
+package prostar_pwm
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// RetryPolicy controls how a Dev retries failed requests. A request is retried up to MaxRetries
+// times if its error matches one of Retryable (DefaultRetryableErrors if nil). The wait before the
+// first retry is Backoff, multiplied by Multiplier (if greater than 1) for each further retry and
+// capped at MaxBackoff (if set). Modbus exception responses and coil writes are never retried, except
+// for the gateway exception reporting that the device did not respond, which counts as a timeout.
+type RetryPolicy struct {
+	MaxRetries int
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	Multiplier float64
+	Retryable  []error
+}
+
+var DefaultRetryableErrors = []error{ErrTimeout, ErrBadCRC, ErrShortFrame, ErrProtocol}
+
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxRetries: 2,
+		Backoff:    100 * time.Millisecond,
+		MaxBackoff: 1 * time.Second,
+		Multiplier: 2,
+	}
+}
+
+func (p RetryPolicy) retryable(err error) bool {
+	// the gateway answered rather than the device, which may well answer the next request
+	if isException(err) && !isTimeout(err) {
+		return false
+	}
+	retryable := p.Retryable
+	if retryable == nil {
+		retryable = DefaultRetryableErrors
+	}
+	for _, target := range retryable {
+		if errorMatches(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	d := p.Backoff
+	for i := 1; (i < retry) && (p.Multiplier > 1); i++ {
+		d = time.Duration(float64(d) * p.Multiplier)
+		if (p.MaxBackoff > 0) && (d >= p.MaxBackoff) {
+			break
+		}
+	}
+	if (p.MaxBackoff > 0) && (d > p.MaxBackoff) {
+		d = p.MaxBackoff
+	}
+	return d
+}
+
+// RetryStats counts the requests made through a retry policy. Retries is the number of repeated
+// attempts, Recovered the requests that succeeded after at least one retry and Failures the requests
+// that failed after their last attempt.
+type RetryStats struct {
+	Requests  uint64
+	Retries   uint64
+	Recovered uint64
+	Failures  uint64
+}
+
+type retryCounters struct {
+	requests  atomic.Uint64
+	retries   atomic.Uint64
+	recovered atomic.Uint64
+	failures  atomic.Uint64
+}
+
+// retryTransport retries the requests of the wrapped Transport. The copy returned by WithContext
+// ends a backoff when ctx is done.
+type retryTransport struct {
+	transport Transport
+	policy    RetryPolicy
+	counters  *retryCounters
+	ctx       context.Context
+}
+
+func (t *retryTransport) WithContext(ctx context.Context) Transport {
+	t2 := *t
+	t2.transport = withContext(t.transport, ctx)
+	t2.ctx = ctx
+	return &t2
+}
+
+func (t *retryTransport) do(f func() error) error {
+	return t.doWithRetries(t.policy.MaxRetries, f)
+}
+
+func (t *retryTransport) doWithRetries(maxRetries int, f func() error) error {
+	t.counters.requests.Add(1)
+	var err error
+	for attempt := 0; ; attempt++ {
+		err = f()
+		if err == nil {
+			if attempt > 0 {
+				t.counters.recovered.Add(1)
+			}
+			return nil
+		}
+		if (attempt >= maxRetries) || !t.policy.retryable(err) {
+			break
+		}
+		if !t.sleep(t.policy.backoff(attempt + 1)) {
+			break
+		}
+		t.counters.retries.Add(1)
+	}
+	t.counters.failures.Add(1)
+	return err
+}
+
+func (t *retryTransport) sleep(d time.Duration) bool {
+	ctx := t.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+func (t *retryTransport) SetUnitId(unitId uint8) error {
+	return t.transport.SetUnitId(unitId)
+}
+
+func (t *retryTransport) ReadRegister(addr uint16, regType RegisterType) (uint16, error) {
+	var v uint16
+	err := t.do(func() error {
+		var err error
+		v, err = t.transport.ReadRegister(addr, regType)
+		return err
+	})
+	return v, err
+}
+
+func (t *retryTransport) ReadRegisters(addr uint16, quantity uint16, regType RegisterType) ([]uint16, error) {
+	var v []uint16
+	err := t.do(func() error {
+		var err error
+		v, err = t.transport.ReadRegisters(addr, quantity, regType)
+		return err
+	})
+	return v, err
+}
+
+func (t *retryTransport) WriteRegister(addr uint16, value uint16) error {
+	return t.do(func() error {
+		return t.transport.WriteRegister(addr, value)
+	})
+}
+
+func (t *retryTransport) ReadCoil(addr uint16) (bool, error) {
+	var v bool
+	err := t.do(func() error {
+		var err error
+		v, err = t.transport.ReadCoil(addr)
+		return err
+	})
+	return v, err
+}
+
+// WriteCoil is never retried: coils trigger actions such as a controller reset, and a failed write
+// may still have been carried out.
+func (t *retryTransport) WriteCoil(addr uint16, value bool) error {
+	return t.doWithRetries(0, func() error {
+		return t.transport.WriteCoil(addr, value)
+	})
+}
+
+// ExecutePDU is never retried either, as a raw request may change the state of the device.
+func (t *retryTransport) ExecutePDU(functionCode uint8, data []byte) ([]byte, error) {
+	if _, ok := t.transport.(PDUTransport); !ok {
+		return nil, ErrPDUNotSupported
+	}
+	var res []byte
+	err := t.doWithRetries(0, func() error {
+		var err error
+		res, err = executePDU(t.transport, functionCode, data)
+		return err
+	})
+	return res, err
+}
+
+// WithRetryPolicy returns a Dev for the same device that retries failed requests according to
+// policy. Its counters are available from RetryStats.
+func (dev *Dev) WithRetryPolicy(policy RetryPolicy) *Dev {
+	transport := dev.transport
+	if rt, ok := transport.(*retryTransport); ok {
+		transport = rt.transport
+	}
+	rt := &retryTransport{
+		transport: transport,
+		policy:    policy,
+		counters:  &retryCounters{},
+	}
+	d := *dev
+	d.transport = rt
+	d.inputRegisters = NewRegisters(rt, InputRegister)
+	d.holdingRegisters = NewRegisters(rt, HoldingRegister)
+	d.retryCounters = rt.counters
+	return &d
+}
+
+// RetryStats returns the counters of the retry policy set with WithRetryPolicy, or zero counters if
+// there is none.
+func (dev *Dev) RetryStats() RetryStats {
+	if dev.retryCounters == nil {
+		return RetryStats{}
+	}
+	return RetryStats{
+		Requests:  dev.retryCounters.requests.Load(),
+		Retries:   dev.retryCounters.retries.Load(),
+		Recovered: dev.retryCounters.recovered.Load(),
+		Failures:  dev.retryCounters.failures.Load(),
+	}
+}
@@ -0,0 +1,110 @@
+package prostar_pwm
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/simonvetter/modbus"
+)
+
+func TestRetry(t *testing.T) {
+	transport := newFakeTransport()
+	transport.err = modbus.ErrRequestTimedOut
+	dev := New(transport, 1, &sync.Mutex{}).WithRetryPolicy(RetryPolicy{
+		MaxRetries: 2,
+		Backoff:    time.Millisecond,
+	})
+
+	_, err := dev.ReadPWMSettings()
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatalf("err = %v, want %v", err, ErrTimeout)
+	}
+	if transport.requests != 3 {
+		t.Errorf("%d requests, want 3", transport.requests)
+	}
+	stats := dev.RetryStats()
+	if (stats.Requests != 1) || (stats.Retries != 2) || (stats.Failures != 1) {
+		t.Errorf("stats = %+v, want 1 request, 2 retries and 1 failure", stats)
+	}
+}
+
+func TestRetryExceptions(t *testing.T) {
+	tests := []struct {
+		err      error
+		requests int
+	}{
+		{err: modbus.ErrGWTargetFailedToRespond, requests: 3},
+		{err: modbus.ErrGWPathUnavailable, requests: 1},
+		{err: modbus.ErrServerDeviceBusy, requests: 1},
+	}
+	for _, test := range tests {
+		t.Run(test.err.Error(), func(t *testing.T) {
+			transport := newFakeTransport()
+			transport.err = test.err
+			dev := New(transport, 1, &sync.Mutex{}).WithRetryPolicy(RetryPolicy{
+				MaxRetries: 2,
+				Backoff:    time.Millisecond,
+			})
+
+			_, err := dev.ReadPWMSettings()
+			if !errors.Is(err, test.err) {
+				t.Fatalf("err = %v, want %v", err, test.err)
+			}
+			if transport.requests != test.requests {
+				t.Errorf("%d requests, want %d", transport.requests, test.requests)
+			}
+		})
+	}
+}
+
+func TestRetryCancel(t *testing.T) {
+	transport := newFakeTransport()
+	transport.err = modbus.ErrRequestTimedOut
+	dev := New(transport, 1, &sync.Mutex{}).WithRetryPolicy(RetryPolicy{
+		MaxRetries: 5,
+		Backoff:    time.Hour,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	done := make(chan error)
+	go func() {
+		_, err := dev.ReadPWMSettingsContext(ctx)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if !errors.Is(err, ErrTimeout) {
+			t.Errorf("err = %v, want %v", err, ErrTimeout)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("backoff not ended by the request context")
+	}
+	if transport.requests != 1 {
+		t.Errorf("%d requests, want 1", transport.requests)
+	}
+}
+
+func TestRetryWriteCoil(t *testing.T) {
+	transport := newFakeTransport()
+	transport.err = modbus.ErrRequestTimedOut
+	dev := New(transport, 1, &sync.Mutex{}).WithRetryPolicy(RetryPolicy{
+		MaxRetries: 2,
+		Backoff:    time.Millisecond,
+	})
+
+	err := dev.SetCoil(CoilResetControl, true)
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatalf("err = %v, want %v", err, ErrTimeout)
+	}
+	if transport.coilWrites != 1 {
+		t.Errorf("%d coil writes, want 1", transport.coilWrites)
+	}
+	stats := dev.RetryStats()
+	if (stats.Retries != 0) || (stats.Failures != 1) {
+		t.Errorf("stats = %+v, want no retries and 1 failure", stats)
+	}
+}
@@ -0,0 +1,208 @@
+package prostar_pwm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/simonvetter/modbus"
+)
+
+const (
+	MinUnitId = 1
+	MaxUnitId = 247
+)
+
+var (
+	ErrUnitIdInUse = errors.New("unit ID in use")
+)
+
+// ScanResult describes a controller that answered during a Scan. Err is set if the controller
+// answered the probe but its details could not be read.
+type ScanResult struct {
+	UnitId                 uint8
+	Hourmeter              *uint32
+	BatteryVoltage         *float32
+	BatteryType            *BatteryType
+	RegulationVoltageAt25C *float32
+	FloatVoltageAt25C      *float32
+	EqualizeVoltageAt25C   *float32
+	LowVoltageDisconnect   *float32
+	LowVoltageReconnect    *float32
+	Err                    error
+}
+
+// ScanProgressFunc is called before each unit ID is probed.
+type ScanProgressFunc func(unitId uint8)
+
+// isNoResponse reports whether err means that no device answered, as opposed to a device answering
+// with an error: a timeout, or a gateway without a path to the device.
+func isNoResponse(err error) bool {
+	return isTimeout(err) || errors.Is(err, modbus.ErrGWPathUnavailable)
+}
+
+type probeContextKey struct{}
+
+// isProbe reports whether ctx belongs to a probe, for which no response is an expected answer rather
+// than a sign of a broken connection.
+func isProbe(ctx context.Context) bool {
+	return (ctx != nil) && (ctx.Value(probeContextKey{}) != nil)
+}
+
+func (dev *Dev) Probe() error {
+	return dev.ProbeContext(context.Background())
+}
+
+// ProbeContext checks that the device answers, using a single short register read. A Bus does not
+// count a probe that times out towards reopening the connection.
+func (dev *Dev) ProbeContext(ctx context.Context) error {
+	dev.mutex.Lock()
+	defer dev.mutex.Unlock()
+
+	ctx = context.WithValue(ctx, probeContextKey{}, true)
+
+	err := dev.requestSetup(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = dev.inputRegisters.WithContext(ctx).readRegisters(0x0036, 2)
+	return err
+}
+
+// Scan probes unitIds (all unit IDs from MinUnitId to MaxUnitId if empty) and returns a result for
+// every controller that answers. The transport timeout determines how long each probe waits, so it
+// should be short.
+func Scan(ctx context.Context, transport Transport, mutex *sync.Mutex, unitIds []uint8, progress ScanProgressFunc) ([]ScanResult, error) {
+	if len(unitIds) == 0 {
+		for unitId := MinUnitId; unitId <= MaxUnitId; unitId++ {
+			unitIds = append(unitIds, uint8(unitId))
+		}
+	}
+
+	var results []ScanResult
+	for _, unitId := range unitIds {
+		if progress != nil {
+			progress(unitId)
+		}
+		dev := New(transport, unitId, mutex)
+		err := dev.ProbeContext(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return results, ctx.Err()
+			}
+			if isNoResponse(err) {
+				continue
+			}
+		}
+		result := ScanResult{
+			UnitId: unitId,
+			Err:    err,
+		}
+		if err == nil {
+			result.Err = dev.readScanDetails(ctx, &result)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+func (dev *Dev) readScanDetails(ctx context.Context, result *ScanResult) error {
+	miscData, err := dev.ReadMiscDataContext(ctx)
+	if err != nil {
+		return err
+	}
+	result.Hourmeter = miscData.Hourmeter
+	if miscData.DIPSwitchDetails != nil {
+		result.BatteryType = &miscData.DIPSwitchDetails.BatteryType
+	}
+
+	filteredADCData, err := dev.ReadFilteredADCDataContext(ctx)
+	if err != nil {
+		return err
+	}
+	result.BatteryVoltage = filteredADCData.BatteryVoltage
+
+	chargeSettings, err := dev.ReadChargeSettingsContext(ctx)
+	if err != nil {
+		return err
+	}
+	result.RegulationVoltageAt25C = chargeSettings.RegulationVoltageAt25C
+	result.FloatVoltageAt25C = chargeSettings.FloatVoltageAt25C
+	result.EqualizeVoltageAt25C = chargeSettings.EqualizeVoltageAt25C
+
+	loadSettings, err := dev.ReadLoadSettingsContext(ctx)
+	if err != nil {
+		return err
+	}
+	result.LowVoltageDisconnect = loadSettings.LowVoltageDisconnect
+	result.LowVoltageReconnect = loadSettings.LowVoltageReconnect
+
+	return nil
+}
+
+func (dev *Dev) SetModbusId(unitId uint8, timeout time.Duration) (*Dev, error) {
+	return dev.SetModbusIdContext(context.Background(), unitId, timeout)
+}
+
+// SetModbusIdContext changes the Modbus ID of the device to unitId and resets the controller so that
+// it takes effect. It returns a Dev for the new unit ID once the device answers there, or an error if
+// it does not within timeout. Another device already answering at unitId is reported as
+// ErrUnitIdInUse.
+func (dev *Dev) SetModbusIdContext(ctx context.Context, unitId uint8, timeout time.Duration) (*Dev, error) {
+	if (unitId < MinUnitId) || (unitId > MaxUnitId) {
+		return nil, fmt.Errorf("invalid unit ID %d (must be %d to %d)", unitId, MinUnitId, MaxUnitId)
+	}
+	if unitId == dev.unitId {
+		return dev, nil
+	}
+
+	newDev := New(dev.transport, unitId, dev.mutex)
+	err := newDev.ProbeContext(ctx)
+	if err == nil {
+		return nil, fmt.Errorf("%w: %d", ErrUnitIdInUse, unitId)
+	}
+	if !isNoResponse(err) {
+		return nil, err
+	}
+
+	err = dev.ProbeContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	modbusId := uint16(unitId)
+	err = dev.WriteMiscSettingsContext(ctx, MiscSettings{
+		ModbusID: &modbusId,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// the controller may reset before it replies
+	err = dev.SetCoilContext(ctx, CoilResetControl, true)
+	if (err != nil) && !isNoResponse(err) {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		err = newDev.ProbeContext(ctx)
+		if err == nil {
+			return newDev, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("device did not answer at unit ID %d within %s: %w", unitId, timeout, err)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(500 * time.Millisecond):
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package prostar_pwm
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/simonvetter/modbus"
+)
+
+func TestIsNoResponse(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{modbus.ErrRequestTimedOut, true},
+		{fmt.Errorf("read /dev/ttyUSB0: %w", os.ErrDeadlineExceeded), true},
+		{modbus.ErrGWTargetFailedToRespond, true},
+		{modbus.ErrGWPathUnavailable, true},
+		{newRegisterError(InputRegister, 0x0036, 2, modbus.ErrRequestTimedOut), true},
+		{modbus.ErrIllegalDataAddress, false},
+		{modbus.ErrBadCRC, false},
+		{errors.New("connection reset"), false},
+	}
+	for _, test := range tests {
+		if got := isNoResponse(test.err); got != test.want {
+			t.Errorf("isNoResponse(%v) = %v, want %v", test.err, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package prostar_pwm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/x448/float16"
+)
+
+// Settings holds all EEPROM settings groups. In a proposed Settings, nil fields are left unchanged.
+type Settings struct {
+	ChargeSettings ChargeSettings
+	LoadSettings   LoadSettings
+	MiscSettings   MiscSettings
+	PWMSettings    PWMSettings
+}
+
+type SettingsChange struct {
+	Group    string
+	Field    string
+	Current  any
+	Proposed any
+}
+
+func (c SettingsChange) Name() string {
+	return c.Group + "." + c.Field
+}
+
+func (dev *Dev) ReadSettings() (Settings, error) {
+	return dev.ReadSettingsContext(context.Background())
+}
+
+// ReadSettingsContext reads all settings groups. With a tolerant Dev, the field errors of all groups
+// are combined into one *PartialReadError.
+func (dev *Dev) ReadSettingsContext(ctx context.Context) (Settings, error) {
+	var s Settings
+	var err error
+	errs := dev.newReadErrors()
+	s.ChargeSettings, err = dev.ReadChargeSettingsContext(ctx)
+	err = errs.merge(err)
+	if err != nil {
+		return Settings{}, err
+	}
+	s.LoadSettings, err = dev.ReadLoadSettingsContext(ctx)
+	err = errs.merge(err)
+	if err != nil {
+		return Settings{}, err
+	}
+	s.MiscSettings, err = dev.ReadMiscSettingsContext(ctx)
+	err = errs.merge(err)
+	if err != nil {
+		return Settings{}, err
+	}
+	s.PWMSettings, err = dev.ReadPWMSettingsContext(ctx)
+	err = errs.merge(err)
+	if err != nil {
+		return Settings{}, err
+	}
+	return s, errs.err()
+}
+
+func (dev *Dev) WriteSettings(s Settings) error {
+	return dev.WriteSettingsContext(context.Background(), s)
+}
+
+// WriteSettingsContext writes the non-nil fields of s, then reads all settings back and checks that
+// every written field holds the requested value.
+func (dev *Dev) WriteSettingsContext(ctx context.Context, s Settings) error {
+	err := dev.WriteChargeSettingsContext(ctx, s.ChargeSettings)
+	if err != nil {
+		return err
+	}
+	err = dev.WriteLoadSettingsContext(ctx, s.LoadSettings)
+	if err != nil {
+		return err
+	}
+	err = dev.WriteMiscSettingsContext(ctx, s.MiscSettings)
+	if err != nil {
+		return err
+	}
+	err = dev.WritePWMSettingsContext(ctx, s.PWMSettings)
+	if err != nil {
+		return err
+	}
+
+	readBack, err := dev.ReadSettingsContext(ctx)
+	if err != nil {
+		return err
+	}
+	changes := DiffSettings(readBack, s)
+	if len(changes) > 0 {
+		var names []string
+		for _, change := range changes {
+			names = append(names, change.Name())
+		}
+		return fmt.Errorf("%w: %s", ErrWriteVerificationFailed, strings.Join(names, ", "))
+	}
+	return nil
+}
+
+// DiffSettings lists the non-nil fields of proposed that differ from current. float32 fields are
+// compared at the float16 precision of the registers.
+func DiffSettings(current Settings, proposed Settings) []SettingsChange {
+	changes, _ := diffSettings(current, proposed)
+	return changes
+}
+
+// ChangedSettings returns proposed with the fields that match current cleared, so that writing it
+// touches only the registers that change.
+func ChangedSettings(current Settings, proposed Settings) Settings {
+	_, changed := diffSettings(current, proposed)
+	return changed
+}
+
+func diffSettings(current Settings, proposed Settings) ([]SettingsChange, Settings) {
+	var changes []SettingsChange
+	var changed Settings
+	cv := reflect.ValueOf(current)
+	pv := reflect.ValueOf(proposed)
+	changedValue := reflect.ValueOf(&changed).Elem()
+	for i := 0; i < pv.NumField(); i++ {
+		group := pv.Type().Field(i).Name
+		currentGroup := cv.Field(i)
+		proposedGroup := pv.Field(i)
+		for j := 0; j < proposedGroup.NumField(); j++ {
+			p := proposedGroup.Field(j)
+			if p.IsNil() {
+				continue
+			}
+			c := currentGroup.Field(j)
+			var currentValue any
+			if !c.IsNil() {
+				currentValue = c.Elem().Interface()
+				if settingValuesEqual(currentValue, p.Elem().Interface()) {
+					continue
+				}
+			}
+			changes = append(changes, SettingsChange{
+				Group:    group,
+				Field:    proposedGroup.Type().Field(j).Name,
+				Current:  currentValue,
+				Proposed: p.Elem().Interface(),
+			})
+			changedValue.Field(i).Field(j).Set(p)
+		}
+	}
+	return changes, changed
+}
+
+func settingValuesEqual(a any, b any) bool {
+	if af, ok := a.(float32); ok {
+		bf, ok := b.(float32)
+		return ok && (float16.Fromfloat32(af) == float16.Fromfloat32(bf))
+	}
+	return a == b
+}
+
+// ValidateSettings validates each settings group; see ValidateChargeSettings and ValidatePWMSettings.
+func ValidateSettings(proposed Settings, current Settings, systemVoltage int, maxChargeCurrent float32) error {
+	var fieldErrors []*FieldError
+	for _, err := range []error{
+		ValidateChargeSettings(proposed.ChargeSettings, current.ChargeSettings, systemVoltage),
+		ValidateLoadSettings(proposed.LoadSettings, current.LoadSettings, systemVoltage),
+		ValidateMiscSettings(proposed.MiscSettings, current.MiscSettings, systemVoltage),
+		ValidatePWMSettings(proposed.PWMSettings, maxChargeCurrent),
+	} {
+		if err == nil {
+			continue
+		}
+		var validationError *ValidationError
+		if !errors.As(err, &validationError) {
+			return err
+		}
+		fieldErrors = append(fieldErrors, validationError.Errors...)
+	}
+	if len(fieldErrors) > 0 {
+		return &ValidationError{Errors: fieldErrors}
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package simulator
+
+import (
+	"fmt"
+
+	"github.com/simonvetter/modbus"
+)
+
+// Handler serves one or more simulated controllers, selected by their current Modbus ID.
+type Handler struct {
+	simulators []*Simulator
+}
+
+func NewHandler(simulators ...*Simulator) *Handler {
+	return &Handler{
+		simulators: simulators,
+	}
+}
+
+func (h *Handler) lookup(unitId uint8) (*Simulator, error) {
+	for _, s := range h.simulators {
+		if s.UnitId() == unitId {
+			return s, nil
+		}
+	}
+	return nil, modbus.ErrGWTargetFailedToRespond
+}
+
+func (h *Handler) HandleCoils(req *modbus.CoilsRequest) ([]bool, error) {
+	s, err := h.lookup(req.UnitId)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if req.IsWrite {
+		for i, v := range req.Args {
+			err = s.writeCoil(req.Addr+uint16(i), v)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return nil, nil
+	}
+
+	values := make([]bool, req.Quantity)
+	for i := range values {
+		values[i], err = s.readCoil(req.Addr + uint16(i))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+func (h *Handler) HandleDiscreteInputs(req *modbus.DiscreteInputsRequest) ([]bool, error) {
+	_, err := h.lookup(req.UnitId)
+	if err != nil {
+		return nil, err
+	}
+	return nil, modbus.ErrIllegalFunction
+}
+
+func (h *Handler) HandleHoldingRegisters(req *modbus.HoldingRegistersRequest) ([]uint16, error) {
+	s, err := h.lookup(req.UnitId)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if req.IsWrite {
+		return nil, s.writeHoldingRegisters(req.Addr, req.Args)
+	}
+	return s.readHoldingRegisters(req.Addr, req.Quantity)
+}
+
+func (h *Handler) HandleInputRegisters(req *modbus.InputRegistersRequest) ([]uint16, error) {
+	s, err := h.lookup(req.UnitId)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.readInputRegisters(req.Addr, req.Quantity)
+}
+
+// DeviceIdentification returns the basic device identification objects of the controller.
+func (h *Handler) DeviceIdentification(unitId uint8) (map[uint8]string, error) {
+	_, err := h.lookup(unitId)
+	if err != nil {
+		return nil, err
+	}
+	return map[uint8]string{
+		0x00: vendorName,
+		0x01: productCode,
+		0x02: fmt.Sprintf("%02x.%02x", softwareVersion>>8, softwareVersion&0xff),
+	}, nil
+}
@@ -0,0 +1,96 @@
+package simulator
+
+import (
+	"errors"
+	"testing"
+
+	prostar_pwm "github.com/ngyewch/prostar-pwm"
+	"github.com/simonvetter/modbus"
+)
+
+func TestHandlerUnknownUnit(t *testing.T) {
+	handler := NewHandler(New(Config{UnitId: 1}))
+
+	_, err := handler.HandleInputRegisters(&modbus.InputRegistersRequest{UnitId: 2, Addr: 0x0000, Quantity: 1})
+	if !errors.Is(err, modbus.ErrGWTargetFailedToRespond) {
+		t.Errorf("err = %v, want %v", err, modbus.ErrGWTargetFailedToRespond)
+	}
+}
+
+func TestHandlerWriteOutsideSettings(t *testing.T) {
+	handler := NewHandler(New(Config{UnitId: 1}))
+
+	tests := []struct {
+		name string
+		addr uint16
+		args []uint16
+	}{
+		{name: "below", addr: firstSettingsRegister - 1, args: []uint16{0}},
+		{name: "above", addr: lastSettingsRegister + 1, args: []uint16{0}},
+		{name: "straddling the end", addr: lastSettingsRegister, args: []uint16{0, 0}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := handler.HandleHoldingRegisters(&modbus.HoldingRegistersRequest{
+				UnitId:   1,
+				Addr:     test.addr,
+				Quantity: uint16(len(test.args)),
+				IsWrite:  true,
+				Args:     test.args,
+			})
+			if !errors.Is(err, modbus.ErrIllegalDataAddress) {
+				t.Errorf("err = %v, want %v", err, modbus.ErrIllegalDataAddress)
+			}
+		})
+	}
+
+	values, err := handler.HandleHoldingRegisters(&modbus.HoldingRegistersRequest{UnitId: 1, Addr: regChargeCurrentLimit, Quantity: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values[0] != encodeFloat16(15) {
+		t.Errorf("charge current limit changed by a rejected write: %#04x", values[0])
+	}
+}
+
+func TestHandlerResetControlAppliesModbusID(t *testing.T) {
+	s := New(Config{UnitId: 1})
+	handler := NewHandler(s)
+
+	_, err := handler.HandleHoldingRegisters(&modbus.HoldingRegistersRequest{
+		UnitId:   1,
+		Addr:     regModbusID,
+		Quantity: 1,
+		IsWrite:  true,
+		Args:     []uint16{5},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.UnitId() != 1 {
+		t.Fatalf("unit ID = %d before the reset, want 1", s.UnitId())
+	}
+
+	_, err = handler.HandleCoils(&modbus.CoilsRequest{
+		UnitId:   1,
+		Addr:     uint16(prostar_pwm.CoilResetControl),
+		Quantity: 1,
+		IsWrite:  true,
+		Args:     []bool{true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.UnitId() != 5 {
+		t.Errorf("unit ID = %d after the reset, want 5", s.UnitId())
+	}
+
+	_, err = handler.HandleInputRegisters(&modbus.InputRegistersRequest{UnitId: 1, Addr: 0x0000, Quantity: 1})
+	if !errors.Is(err, modbus.ErrGWTargetFailedToRespond) {
+		t.Errorf("old unit ID: err = %v, want %v", err, modbus.ErrGWTargetFailedToRespond)
+	}
+	_, err = handler.HandleInputRegisters(&modbus.InputRegistersRequest{UnitId: 5, Addr: 0x0000, Quantity: 1})
+	if err != nil {
+		t.Errorf("new unit ID: %v", err)
+	}
+}
@@ -0,0 +1,226 @@
+package simulator
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/simonvetter/modbus"
+)
+
+const (
+	maxReadRegisters = 125
+	maxReadCoils     = 2000
+)
+
+const (
+	fcReadCoils                      = 0x01
+	fcReadDiscreteInputs             = 0x02
+	fcReadHoldingRegisters           = 0x03
+	fcReadInputRegisters             = 0x04
+	fcWriteSingleCoil                = 0x05
+	fcWriteSingleRegister            = 0x06
+	fcWriteMultipleCoils             = 0x0f
+	fcWriteMultipleRegisters         = 0x10
+	fcEncapsulatedInterfaceTransport = 0x2b
+
+	meiReadDeviceIdentification = 0x0e
+)
+
+// DeviceIdentifier is implemented by a modbus.RequestHandler that answers Read Device Identification
+// (function 0x2B/0x0E). DeviceIdentification returns the basic device identification objects, keyed
+// by object ID, or modbus.ErrGWTargetFailedToRespond for an unknown unit ID.
+type DeviceIdentifier interface {
+	DeviceIdentification(unitId uint8) (map[uint8]string, error)
+}
+
+// handlePDU handles the request PDU req (function code and data) for unitId and returns the response
+// PDU. An exception is returned as an error.
+func handlePDU(unitId uint8, req []byte, handler modbus.RequestHandler) ([]byte, error) {
+	if len(req) < 1 {
+		return nil, modbus.ErrIllegalFunction
+	}
+	fc := req[0]
+	var addr, quantity uint16
+	if len(req) >= 5 {
+		addr = binary.BigEndian.Uint16(req[1:3])
+		quantity = binary.BigEndian.Uint16(req[3:5])
+	}
+
+	res := []byte{fc}
+	switch fc {
+	case fcReadCoils, fcReadDiscreteInputs:
+		if (quantity == 0) || (quantity > maxReadCoils) {
+			return nil, modbus.ErrIllegalDataValue
+		}
+		var values []bool
+		var err error
+		if fc == fcReadCoils {
+			values, err = handler.HandleCoils(&modbus.CoilsRequest{UnitId: unitId, Addr: addr, Quantity: quantity})
+		} else {
+			values, err = handler.HandleDiscreteInputs(&modbus.DiscreteInputsRequest{UnitId: unitId, Addr: addr, Quantity: quantity})
+		}
+		if err != nil {
+			return nil, err
+		}
+		packed := packBools(values)
+		res = append(res, byte(len(packed)))
+		return append(res, packed...), nil
+
+	case fcReadHoldingRegisters, fcReadInputRegisters:
+		if (quantity == 0) || (quantity > maxReadRegisters) {
+			return nil, modbus.ErrIllegalDataValue
+		}
+		var values []uint16
+		var err error
+		if fc == fcReadHoldingRegisters {
+			values, err = handler.HandleHoldingRegisters(&modbus.HoldingRegistersRequest{UnitId: unitId, Addr: addr, Quantity: quantity})
+		} else {
+			values, err = handler.HandleInputRegisters(&modbus.InputRegistersRequest{UnitId: unitId, Addr: addr, Quantity: quantity})
+		}
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, byte(2*len(values)))
+		for _, v := range values {
+			res = binary.BigEndian.AppendUint16(res, v)
+		}
+		return res, nil
+
+	case fcWriteSingleCoil:
+		if (quantity != 0xff00) && (quantity != 0x0000) {
+			return nil, modbus.ErrIllegalDataValue
+		}
+		_, err := handler.HandleCoils(&modbus.CoilsRequest{UnitId: unitId, Addr: addr, Quantity: 1, IsWrite: true, Args: []bool{quantity == 0xff00}})
+		if err != nil {
+			return nil, err
+		}
+		return append(res, req[1:5]...), nil
+
+	case fcWriteSingleRegister:
+		_, err := handler.HandleHoldingRegisters(&modbus.HoldingRegistersRequest{UnitId: unitId, Addr: addr, Quantity: 1, IsWrite: true, Args: []uint16{quantity}})
+		if err != nil {
+			return nil, err
+		}
+		return append(res, req[1:5]...), nil
+
+	case fcWriteMultipleCoils:
+		if len(req) < 6 {
+			return nil, modbus.ErrIllegalDataValue
+		}
+		data := req[6:]
+		if (quantity == 0) || (int(quantity) > len(data)*8) {
+			return nil, modbus.ErrIllegalDataValue
+		}
+		args := make([]bool, quantity)
+		for i := range args {
+			args[i] = (data[i/8]>>(i%8))&1 == 1
+		}
+		_, err := handler.HandleCoils(&modbus.CoilsRequest{UnitId: unitId, Addr: addr, Quantity: quantity, IsWrite: true, Args: args})
+		if err != nil {
+			return nil, err
+		}
+		return append(res, req[1:5]...), nil
+
+	case fcWriteMultipleRegisters:
+		if len(req) < 6 {
+			return nil, modbus.ErrIllegalDataValue
+		}
+		data := req[6:]
+		if (quantity == 0) || (int(quantity)*2 != len(data)) {
+			return nil, modbus.ErrIllegalDataValue
+		}
+		args := make([]uint16, quantity)
+		for i := range args {
+			args[i] = binary.BigEndian.Uint16(data[2*i:])
+		}
+		_, err := handler.HandleHoldingRegisters(&modbus.HoldingRegistersRequest{UnitId: unitId, Addr: addr, Quantity: quantity, IsWrite: true, Args: args})
+		if err != nil {
+			return nil, err
+		}
+		return append(res, req[1:5]...), nil
+
+	case fcEncapsulatedInterfaceTransport:
+		identifier, ok := handler.(DeviceIdentifier)
+		if !ok || (len(req) < 2) || (req[1] != meiReadDeviceIdentification) {
+			return nil, modbus.ErrIllegalFunction
+		}
+		if len(req) != 4 {
+			return nil, modbus.ErrIllegalDataValue
+		}
+		objects, err := identifier.DeviceIdentification(unitId)
+		if err != nil {
+			return nil, err
+		}
+		return readDeviceIdentificationResponse(objects, req[2], req[3])
+	}
+
+	return nil, modbus.ErrIllegalFunction
+}
+
+// readDeviceIdentificationResponse answers a Read Device Identification request with the given read
+// device ID code and object ID. Only the basic objects 0x00-0x02 exist; the regular and extended
+// stream codes return those too. All objects fit in one response.
+func readDeviceIdentificationResponse(objects map[uint8]string, code uint8, objectId uint8) ([]byte, error) {
+	var ids []uint8
+	switch code {
+	case 0x01, 0x02, 0x03:
+		// stream access; an unknown object ID restarts at the first object
+		if _, ok := objects[objectId]; !ok {
+			objectId = 0x00
+		}
+		for id := objectId; id <= 0x02; id++ {
+			if _, ok := objects[id]; ok {
+				ids = append(ids, id)
+			}
+		}
+	case 0x04:
+		// individual access
+		if _, ok := objects[objectId]; !ok {
+			return nil, modbus.ErrIllegalDataAddress
+		}
+		ids = []uint8{objectId}
+	default:
+		return nil, modbus.ErrIllegalDataValue
+	}
+
+	// basic identification, stream and individual access
+	const conformityLevel = 0x81
+	res := []byte{fcEncapsulatedInterfaceTransport, meiReadDeviceIdentification, code, conformityLevel, 0x00, 0x00, byte(len(ids))}
+	for _, id := range ids {
+		res = append(res, id, byte(len(objects[id])))
+		res = append(res, objects[id]...)
+	}
+	return res, nil
+}
+
+// exceptionPDU returns the exception response to function code fc for err.
+func exceptionPDU(fc uint8, err error) []byte {
+	return []byte{fc | 0x80, exceptionCode(err)}
+}
+
+func exceptionCode(err error) byte {
+	switch {
+	case errors.Is(err, modbus.ErrIllegalFunction):
+		return 0x01
+	case errors.Is(err, modbus.ErrIllegalDataAddress):
+		return 0x02
+	case errors.Is(err, modbus.ErrIllegalDataValue):
+		return 0x03
+	case errors.Is(err, modbus.ErrGWPathUnavailable):
+		return 0x0a
+	case errors.Is(err, modbus.ErrGWTargetFailedToRespond):
+		return 0x0b
+	default:
+		return 0x04
+	}
+}
+
+func packBools(values []bool) []byte {
+	packed := make([]byte, (len(values)+7)/8)
+	for i, v := range values {
+		if v {
+			packed[i/8] |= 1 << (i % 8)
+		}
+	}
+	return packed
+}
@@ -0,0 +1,385 @@
+package simulator
+
+import (
+	"fmt"
+	"math"
+
+	prostar_pwm "github.com/ngyewch/prostar-pwm"
+	"github.com/simonvetter/modbus"
+	"github.com/x448/float16"
+)
+
+const (
+	logAddress     = 0x8000
+	logRecordCount = 256
+	logRecordSize  = 16
+
+	// switches 4-6 (custom EEPROM settings) and 8 (Modbus) on
+	dipSwitch = 0x00b8
+
+	softwareVersion = 0x0213
+	hardwareVersion = 0x0100
+
+	// device identification objects
+	vendorName  = "Morningstar Corp."
+	productCode = "PS-PWM"
+)
+
+const (
+	regRegulationVoltage                      = 0xe000
+	regFloatVoltage                           = 0xe001
+	regTimeBeforeEnteringFloat                = 0xe002
+	regTimeBeforeEnteringFloatDueToLowBattery = 0xe003
+	regVoltageTriggerForLowBatteryFloatTime   = 0xe004
+	regVoltageToCancelFloat                   = 0xe005
+	regExitFloatTime                          = 0xe006
+	regEqualizeVoltage                        = 0xe007
+	regDaysBetweenEQCycles                    = 0xe008
+	regEqualizeTimeLimitAboveEVReg            = 0xe009
+	regEqualizeTimeLimitAtEVEq                = 0xe00a
+	regReferenceChargeVoltageLimit            = 0xe010
+	regTemperatureCompensationCoefficient     = 0xe01a
+	regHighVoltageDisconnect                  = 0xe01b
+	regHighVoltageReconnect                   = 0xe01c
+	regMaximumChargeVoltageReference          = 0xe01d
+	regMaxBatteryTempCompensationLimit        = 0xe01e
+	regMinBatteryTempCompensationLimit        = 0xe01f
+	regLowVoltageDisconnect                   = 0xe022
+	regLowVoltageReconnect                    = 0xe023
+	regLoadHighVoltageDisconnect              = 0xe024
+	regLoadHighVoltageReconnect               = 0xe025
+	regLVDLoadCurrentCompensation             = 0xe026
+	regLVDWarningTimeout                      = 0xe027
+	regLEDGreenToGreenAndYellowLimit          = 0xe030
+	regLEDGreenAndYellowToYellowLimit         = 0xe031
+	regLEDYellowToYellowAndRedLimit           = 0xe032
+	regLEDYellowAndRedToRedFlashingLimit      = 0xe033
+	regModbusID                               = 0xe034
+	regMeterbusID                             = 0xe035
+	regChargeCurrentLimit                     = 0xe038
+	regSerialNumber                           = 0xe0c0
+	regHardwareVersion                        = 0xe0cc
+
+	firstInputRegister      = 0x0000
+	lastInputRegister       = 0x004f
+	firstSettingsRegister   = 0xe000
+	lastSettingsRegister    = 0xe038
+	firstStatisticsRegister = 0xe040
+	lastStatisticsRegister  = 0xe04f
+)
+
+func (s *Simulator) initEEPROM() {
+	for addr := uint16(firstSettingsRegister); addr <= lastSettingsRegister; addr++ {
+		s.eeprom[addr] = 0
+	}
+	v := s.scale
+	s.setEEPROMFloat(regRegulationVoltage, 14.4*v)
+	s.setEEPROMFloat(regFloatVoltage, 13.7*v)
+	s.eeprom[regTimeBeforeEnteringFloat] = 3 * 3600
+	s.eeprom[regTimeBeforeEnteringFloatDueToLowBattery] = 4 * 3600
+	s.setEEPROMFloat(regVoltageTriggerForLowBatteryFloatTime, 12.5*v)
+	s.setEEPROMFloat(regVoltageToCancelFloat, 12.3*v)
+	s.eeprom[regExitFloatTime] = 3600
+	s.setEEPROMFloat(regEqualizeVoltage, 14.9*v)
+	s.eeprom[regDaysBetweenEQCycles] = 28
+	s.eeprom[regEqualizeTimeLimitAboveEVReg] = 3 * 3600
+	s.eeprom[regEqualizeTimeLimitAtEVEq] = 2 * 3600
+	s.setEEPROMFloat(regReferenceChargeVoltageLimit, 15.0*v)
+	s.setEEPROMFloat(regTemperatureCompensationCoefficient, 0.03*v)
+	s.setEEPROMFloat(regHighVoltageDisconnect, 15.2*v)
+	s.setEEPROMFloat(regHighVoltageReconnect, 14.5*v)
+	s.setEEPROMFloat(regMaximumChargeVoltageReference, 0)
+	s.eeprom[regMaxBatteryTempCompensationLimit] = encodeInt16(60)
+	s.eeprom[regMinBatteryTempCompensationLimit] = encodeInt16(-30)
+	s.setEEPROMFloat(regLowVoltageDisconnect, 11.5*v)
+	s.setEEPROMFloat(regLowVoltageReconnect, 12.6*v)
+	s.setEEPROMFloat(regLoadHighVoltageDisconnect, 15.3*v)
+	s.setEEPROMFloat(regLoadHighVoltageReconnect, 14.6*v)
+	s.setEEPROMFloat(regLVDLoadCurrentCompensation, 0.01*v)
+	s.eeprom[regLVDWarningTimeout] = 5
+	s.setEEPROMFloat(regLEDGreenToGreenAndYellowLimit, 13.3*v)
+	s.setEEPROMFloat(regLEDGreenAndYellowToYellowLimit, 13.0*v)
+	s.setEEPROMFloat(regLEDYellowToYellowAndRedLimit, 12.65*v)
+	s.setEEPROMFloat(regLEDYellowAndRedToRedFlashingLimit, 12.3*v)
+	s.eeprom[regModbusID] = uint16(s.unitId)
+	s.eeprom[regMeterbusID] = 1
+	s.setEEPROMFloat(regChargeCurrentLimit, 15)
+
+	serial := fmt.Sprintf("%08d", 16000000+int(s.unitId))
+	for i := 0; i < 4; i++ {
+		s.eeprom[regSerialNumber+uint16(i)] = uint16(serial[2*i]) | uint16(serial[2*i+1])<<8
+	}
+	s.eeprom[regHardwareVersion] = hardwareVersion
+}
+
+func (s *Simulator) eepromFloat(addr uint16) float64 {
+	return float64(float16.Frombits(s.eeprom[addr]).Float32())
+}
+
+func (s *Simulator) setEEPROMFloat(addr uint16, v float64) {
+	s.eeprom[addr] = float16.Fromfloat32(float32(v)).Bits()
+}
+
+func encodeFloat16(v float64) uint16 {
+	return float16.Fromfloat32(float32(v)).Bits()
+}
+
+func encodeInt16(v int16) uint16 {
+	return uint16(v)
+}
+
+func encodeScaled(v float64, multiplier float64) uint32 {
+	return uint32(math.Max(0, math.Round(v*multiplier)))
+}
+
+func setUint32HighFirst(m map[uint16]uint16, addr uint16, v uint32) {
+	m[addr] = uint16(v >> 16)
+	m[addr+1] = uint16(v)
+}
+
+func setUint32LowFirst(m map[uint16]uint16, addr uint16, v uint32) {
+	m[addr] = uint16(v)
+	m[addr+1] = uint16(v >> 16)
+}
+
+func boolToUint16(v bool) uint16 {
+	if v {
+		return 1
+	}
+	return 0
+}
+
+// refresh publishes the model state into the register maps.
+func (s *Simulator) refresh() {
+	for addr := uint16(firstInputRegister); addr <= lastInputRegister; addr++ {
+		s.input[addr] = 0
+	}
+	in := s.input
+	in[0x0000] = softwareVersion
+	loadOn := s.loadVoltage > 0
+	charging := s.arrayCurrent > 0.01
+
+	in[0x0004] = encodeFloat16(3.3)
+	in[0x0005] = encodeFloat16(12.1)
+	in[0x0006] = encodeFloat16(9.8)
+	in[0x0007] = encodeFloat16(1.21)
+	in[0x0008] = encodeFloat16(-2.4)
+	in[0x0009] = encodeFloat16(float64(boolToUint16(loadOn)) * (s.batteryVoltage + 10))
+	in[0x000a] = encodeFloat16(float64(boolToUint16(charging)) * (s.batteryVoltage + 10))
+
+	in[0x0011] = encodeFloat16(s.arrayCurrent)
+	in[0x0012] = encodeFloat16(s.batteryVoltage)
+	in[0x0013] = encodeFloat16(s.arrayVoltage)
+	in[0x0014] = encodeFloat16(s.loadVoltage)
+	in[0x0016] = encodeFloat16(s.loadCurrent)
+	in[0x0017] = encodeFloat16(s.batteryVoltage)
+	in[0x0018] = encodeFloat16(s.batteryVoltage)
+	in[0x0019] = encodeFloat16(s.batteryCurrent)
+
+	in[0x001a] = encodeFloat16(s.heatsinkTemp)
+	in[0x001b] = encodeFloat16(s.batteryTemp)
+	in[0x001c] = encodeFloat16(s.ambient)
+	in[0x001d] = encodeFloat16(s.batteryTemp)
+
+	in[0x0021] = uint16(s.chargeState)
+	in[0x0022] = 0
+	in[0x0023] = encodeFloat16(s.batteryVoltage)
+	in[0x0024] = encodeFloat16(s.referenceV)
+	setUint32HighFirst(in, 0x0026, encodeScaled(s.ahChargeResettable, 10))
+	setUint32HighFirst(in, 0x0028, encodeScaled(s.ahChargeTotal, 10))
+	in[0x002a] = uint16(encodeScaled(s.kWhChargeResettable, 10))
+	in[0x002b] = uint16(encodeScaled(s.kWhChargeTotal, 10))
+	in[0x002c] = encodeFloat16(-10)
+	in[0x002d] = encodeFloat16(-20)
+
+	in[0x002e] = uint16(s.loadState)
+	in[0x002f] = 0
+	in[0x0030] = encodeFloat16(s.eepromFloat(regLowVoltageDisconnect) - s.loadCurrent*s.eepromFloat(regLVDLoadCurrentCompensation))
+	in[0x0031] = encodeFloat16(s.eepromFloat(regLoadHighVoltageDisconnect))
+	setUint32HighFirst(in, 0x0032, encodeScaled(s.ahLoadResettable, 10))
+	setUint32HighFirst(in, 0x0034, encodeScaled(s.ahLoadTotal, 10))
+
+	setUint32HighFirst(in, 0x0036, uint32(s.hourmeter))
+	setUint32HighFirst(in, 0x0038, s.alarm)
+	in[0x003a] = dipSwitch
+	in[0x003b] = uint16(s.ledState())
+	in[0x004d] = uint16(s.chargeStatusLEDState())
+	in[0x004e] = boolToUint16(s.sun() == 0)
+
+	for addr := uint16(firstStatisticsRegister); addr <= lastStatisticsRegister; addr++ {
+		s.eeprom[addr] = 0
+	}
+	setUint32LowFirst(s.eeprom, 0xe040, uint32(s.hourmeter))
+	setUint32LowFirst(s.eeprom, 0xe042, encodeScaled(s.ahLoadResettable, 10))
+	setUint32LowFirst(s.eeprom, 0xe044, encodeScaled(s.ahLoadTotal, 10))
+	setUint32LowFirst(s.eeprom, 0xe046, encodeScaled(s.ahChargeResettable, 10))
+	setUint32LowFirst(s.eeprom, 0xe048, encodeScaled(s.ahChargeTotal, 10))
+	s.eeprom[0xe04a] = uint16(encodeScaled(s.kWhChargeResettable, 10))
+	s.eeprom[0xe04b] = uint16(encodeScaled(s.kWhChargeTotal, 10))
+	s.eeprom[0xe04c] = encodeFloat16(s.vbMin)
+	s.eeprom[0xe04d] = encodeFloat16(s.vbMax)
+	s.eeprom[0xe04e] = encodeFloat16(s.vaMax)
+	s.eeprom[0xe04f] = uint16(s.now.Sub(s.lastEqualize).Hours() / 24)
+}
+
+func (s *Simulator) appendLogRecord() {
+	r := s.log[s.logIndex*logRecordSize : (s.logIndex+1)*logRecordSize]
+	hourmeter := uint32(s.hourmeter)
+	r[0] = uint16(hourmeter)
+	r[1] = uint16(hourmeter >> 16)
+	r[2] = uint16(s.daily.alarm)
+	r[3] = uint16(s.daily.alarm >> 16)
+	r[4] = 0
+	r[5] = 0
+	r[6] = 0
+	r[7] = 0
+	r[8] = encodeFloat16(s.daily.vbMin)
+	r[9] = encodeFloat16(s.daily.vbMax)
+	r[10] = encodeFloat16(s.daily.ahCharge)
+	r[11] = encodeFloat16(s.daily.ahLoad)
+	r[12] = encodeFloat16(s.daily.vaMax)
+	r[13] = uint16(s.daily.absorption / 60)
+	r[14] = uint16(s.daily.equalize / 60)
+	r[15] = uint16(s.daily.float / 60)
+	s.logIndex = (s.logIndex + 1) % logRecordCount
+}
+
+func (s *Simulator) ledState() prostar_pwm.LEDState {
+	switch {
+	case s.loadState == prostar_pwm.LoadStateLVD:
+		return prostar_pwm.LEDStateRedLED
+	case s.batteryVoltage >= s.eepromFloat(regLEDGreenToGreenAndYellowLimit):
+		return prostar_pwm.LEDStateGreenLED
+	case s.batteryVoltage >= s.eepromFloat(regLEDGreenAndYellowToYellowLimit):
+		return prostar_pwm.LEDStateGreenYellowLED
+	case s.batteryVoltage >= s.eepromFloat(regLEDYellowToYellowAndRedLimit):
+		return prostar_pwm.LEDStateYellowLED
+	case s.batteryVoltage >= s.eepromFloat(regLEDYellowAndRedToRedFlashingLimit):
+		return prostar_pwm.LEDStateYellowRedLED
+	default:
+		return prostar_pwm.LEDStateBlinkRedLED
+	}
+}
+
+func (s *Simulator) chargeStatusLEDState() prostar_pwm.ChargeStatusLEDState {
+	switch {
+	case s.chargeState == prostar_pwm.ChargeStateEqualize:
+		return prostar_pwm.ChargeStatusLEDStateEqualize
+	case s.chargeState == prostar_pwm.ChargeStateFloat:
+		return prostar_pwm.ChargeStatusLEDStateFloat
+	case s.chargeState == prostar_pwm.ChargeStateAbsorption:
+		return prostar_pwm.ChargeStatusLEDStateAbsorption
+	case s.batteryVoltage >= s.eepromFloat(regLEDGreenToGreenAndYellowLimit):
+		return prostar_pwm.ChargeStatusLEDStateGreenLED
+	case s.batteryVoltage >= s.eepromFloat(regLEDGreenAndYellowToYellowLimit):
+		return prostar_pwm.ChargeStatusLEDStateGreenYellowLED
+	default:
+		return prostar_pwm.ChargeStatusLEDStateYellowLED
+	}
+}
+
+func (s *Simulator) readInputRegisters(addr uint16, quantity uint16) ([]uint16, error) {
+	values := make([]uint16, quantity)
+	for i := range values {
+		a := addr + uint16(i)
+		if (a >= logAddress) && (int(a) < logAddress+len(s.log)) {
+			values[i] = s.log[a-logAddress]
+			continue
+		}
+		v, ok := s.input[a]
+		if !ok {
+			return nil, modbus.ErrIllegalDataAddress
+		}
+		values[i] = v
+	}
+	return values, nil
+}
+
+func (s *Simulator) readHoldingRegisters(addr uint16, quantity uint16) ([]uint16, error) {
+	values := make([]uint16, quantity)
+	for i := range values {
+		v, ok := s.eeprom[addr+uint16(i)]
+		if !ok {
+			return nil, modbus.ErrIllegalDataAddress
+		}
+		values[i] = v
+	}
+	return values, nil
+}
+
+func (s *Simulator) writeHoldingRegisters(addr uint16, values []uint16) error {
+	for i := range values {
+		a := addr + uint16(i)
+		if (a < firstSettingsRegister) || (a > lastSettingsRegister) {
+			return modbus.ErrIllegalDataAddress
+		}
+	}
+	for i, v := range values {
+		s.eeprom[addr+uint16(i)] = v
+	}
+	s.refresh()
+	return nil
+}
+
+func (s *Simulator) readCoil(addr uint16) (bool, error) {
+	switch prostar_pwm.Coil(addr) {
+	case prostar_pwm.CoilEqualizeTriggered:
+		return s.equalize, nil
+	case prostar_pwm.CoilLoadDisconnect:
+		return s.disconnect, nil
+	case prostar_pwm.CoilClearAhResettable, prostar_pwm.CoilClearKWhResettable, prostar_pwm.CoilClearFaults,
+		prostar_pwm.CoilClearAlarms, prostar_pwm.CoilClearLoggedData, prostar_pwm.CoilResetControl:
+		return false, nil
+	default:
+		return false, modbus.ErrIllegalDataAddress
+	}
+}
+
+func (s *Simulator) writeCoil(addr uint16, value bool) error {
+	switch prostar_pwm.Coil(addr) {
+	case prostar_pwm.CoilEqualizeTriggered:
+		s.equalize = value
+		if !value && (s.chargeState == prostar_pwm.ChargeStateEqualize) {
+			s.setChargeState(prostar_pwm.ChargeStateFloat)
+		}
+	case prostar_pwm.CoilLoadDisconnect:
+		s.disconnect = value
+	case prostar_pwm.CoilClearAhResettable:
+		if value {
+			s.ahChargeResettable = 0
+			s.ahLoadResettable = 0
+		}
+	case prostar_pwm.CoilClearKWhResettable:
+		if value {
+			s.kWhChargeResettable = 0
+		}
+	case prostar_pwm.CoilClearFaults:
+	case prostar_pwm.CoilClearAlarms:
+		if value {
+			s.daily.alarm = 0
+		}
+	case prostar_pwm.CoilClearLoggedData:
+		if value {
+			clear(s.log)
+			s.logIndex = 0
+		}
+	case prostar_pwm.CoilResetControl:
+		if value {
+			s.reset()
+		}
+	default:
+		return modbus.ErrIllegalDataAddress
+	}
+	s.refresh()
+	return nil
+}
+
+func (s *Simulator) reset() {
+	id := s.eeprom[regModbusID]
+	if (id >= 1) && (id <= 247) {
+		s.unitId = uint8(id)
+	}
+	s.equalize = false
+	s.setChargeState(prostar_pwm.ChargeStateStart)
+	s.setLoadState(prostar_pwm.LoadStateStart)
+}
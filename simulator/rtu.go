@@ -0,0 +1,104 @@
+package simulator
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"github.com/simonvetter/modbus"
+)
+
+const (
+	maxRTUFrameLength = 256
+)
+
+// ServeRTU reads Modbus RTU request frames from rw and writes the responses back until rw returns
+// an error. Requests for unit IDs not served by the handler are ignored, as they would be on a
+// shared RS-485 bus.
+func ServeRTU(rw io.ReadWriter, handler modbus.RequestHandler) error {
+	var buf []byte
+	chunk := make([]byte, maxRTUFrameLength)
+	for {
+		n, err := rw.Read(chunk)
+		if err != nil {
+			return err
+		}
+		buf = append(buf, chunk[:n]...)
+
+		for {
+			length, ok := rtuRequestLength(buf)
+			if !ok {
+				if len(buf) > maxRTUFrameLength {
+					buf = nil
+				}
+				break
+			}
+			if length < 0 {
+				// unknown function code; drop everything and resynchronise on the next frame
+				buf = nil
+				break
+			}
+			frame := buf[:length]
+			buf = buf[length:]
+			if crc16(frame[:length-2]) != binary.LittleEndian.Uint16(frame[length-2:]) {
+				buf = nil
+				break
+			}
+			unitId := frame[0]
+			res, err := handlePDU(unitId, frame[1:length-2], handler)
+			if errors.Is(err, modbus.ErrGWTargetFailedToRespond) {
+				continue
+			}
+			if err != nil {
+				res = exceptionPDU(frame[1], err)
+			}
+			res = append([]byte{unitId}, res...)
+			res = binary.LittleEndian.AppendUint16(res, crc16(res))
+			_, err = rw.Write(res)
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// rtuRequestLength returns the length of the request frame at the start of buf, including the CRC,
+// or -1 if the function code is unknown. ok is false if more bytes are needed.
+func rtuRequestLength(buf []byte) (length int, ok bool) {
+	if len(buf) < 2 {
+		return 0, false
+	}
+	switch buf[1] {
+	case fcReadCoils, fcReadDiscreteInputs, fcReadHoldingRegisters, fcReadInputRegisters,
+		fcWriteSingleCoil, fcWriteSingleRegister:
+		length = 8
+	case fcWriteMultipleCoils, fcWriteMultipleRegisters:
+		if len(buf) < 7 {
+			return 0, false
+		}
+		length = 9 + int(buf[6])
+	case fcEncapsulatedInterfaceTransport:
+		length = 7
+	default:
+		return -1, true
+	}
+	if len(buf) < length {
+		return 0, false
+	}
+	return length, true
+}
+
+func crc16(b []byte) uint16 {
+	crc := uint16(0xffff)
+	for _, c := range b {
+		crc ^= uint16(c)
+		for i := 0; i < 8; i++ {
+			if crc&1 == 1 {
+				crc = (crc >> 1) ^ 0xa001
+			} else {
+				crc >>= 1
+			}
+		}
+	}
+	return crc
+}
@@ -0,0 +1,120 @@
+package simulator
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+)
+
+// rtuLine is the controller's end of a serial line. Each read returns the next frame sent by the
+// client, and the responses written are collected in out.
+type rtuLine struct {
+	in  [][]byte
+	out bytes.Buffer
+}
+
+func (l *rtuLine) Read(p []byte) (int, error) {
+	if len(l.in) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p, l.in[0])
+	l.in = l.in[1:]
+	return n, nil
+}
+
+func (l *rtuLine) Write(p []byte) (int, error) {
+	return l.out.Write(p)
+}
+
+func rtuFrame(b ...byte) []byte {
+	return binary.LittleEndian.AppendUint16(b, crc16(b))
+}
+
+func TestCRC16(t *testing.T) {
+	// read holding register 0x0000 of unit 1, from the Modbus over serial line specification
+	frame := rtuFrame(0x01, 0x03, 0x00, 0x00, 0x00, 0x01)
+	want := []byte{0x01, 0x03, 0x00, 0x00, 0x00, 0x01, 0x84, 0x0a}
+	if !bytes.Equal(frame, want) {
+		t.Errorf("frame = % x, want % x", frame, want)
+	}
+}
+
+func TestServeRTU(t *testing.T) {
+	corrupted := rtuFrame(0x01, 0x03, 0xe0, 0x34, 0x00, 0x01)
+	corrupted[len(corrupted)-1] ^= 0xff
+
+	tests := []struct {
+		name     string
+		request  []byte
+		response []byte
+	}{
+		{
+			name:     "read holding register",
+			request:  rtuFrame(0x01, 0x03, 0xe0, 0x34, 0x00, 0x01),
+			response: rtuFrame(0x01, 0x03, 0x02, 0x00, 0x01),
+		},
+		{
+			name:     "write single register",
+			request:  rtuFrame(0x01, 0x06, 0xe0, 0x35, 0x00, 0x02),
+			response: rtuFrame(0x01, 0x06, 0xe0, 0x35, 0x00, 0x02),
+		},
+		{
+			name:     "write outside the settings range",
+			request:  rtuFrame(0x01, 0x06, 0xe0, 0x40, 0x00, 0x02),
+			response: rtuFrame(0x01, 0x86, 0x02),
+		},
+		{
+			name:     "unknown function",
+			request:  rtuFrame(0x01, 0x41, 0x00, 0x00),
+			response: nil,
+		},
+		{
+			name:     "read device identification",
+			request:  rtuFrame(0x01, 0x2b, 0x0e, 0x04, 0x00),
+			response: rtuFrame(append([]byte{0x01, 0x2b, 0x0e, 0x04, 0x81, 0x00, 0x00, 0x01, 0x00, byte(len(vendorName))}, vendorName...)...),
+		},
+		{
+			name:     "read unknown device identification object",
+			request:  rtuFrame(0x01, 0x2b, 0x0e, 0x04, 0x80),
+			response: rtuFrame(0x01, 0xab, 0x02),
+		},
+		{
+			name:    "corrupted CRC",
+			request: corrupted,
+		},
+		{
+			name:    "unknown unit ID",
+			request: rtuFrame(0x02, 0x03, 0xe0, 0x34, 0x00, 0x01),
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			handler := NewHandler(New(Config{UnitId: 1}))
+			line := &rtuLine{in: [][]byte{test.request}}
+			err := ServeRTU(line, handler)
+			if !errors.Is(err, io.EOF) {
+				t.Fatalf("err = %v, want %v", err, io.EOF)
+			}
+			if !bytes.Equal(line.out.Bytes(), test.response) {
+				t.Errorf("response = % x, want % x", line.out.Bytes(), test.response)
+			}
+		})
+	}
+}
+
+// TestServeRTUSplitFrame checks that a request arriving in several reads is reassembled.
+func TestServeRTUSplitFrame(t *testing.T) {
+	handler := NewHandler(New(Config{UnitId: 1}))
+	request := rtuFrame(0x01, 0x10, 0xe0, 0x35, 0x00, 0x01, 0x02, 0x00, 0x03)
+	line := &rtuLine{in: [][]byte{request[:3], request[3:7], request[7:]}}
+	err := ServeRTU(line, handler)
+	if !errors.Is(err, io.EOF) {
+		t.Fatalf("err = %v, want %v", err, io.EOF)
+	}
+	want := rtuFrame(0x01, 0x10, 0xe0, 0x35, 0x00, 0x01)
+	if !bytes.Equal(line.out.Bytes(), want) {
+		t.Errorf("response = % x, want % x", line.out.Bytes(), want)
+	}
+}
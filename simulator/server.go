@@ -0,0 +1,113 @@
+package simulator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+
+	"github.com/creack/pty"
+	"github.com/simonvetter/modbus"
+)
+
+// Serve listens on the given URL until ctx is done. Supported schemes are tcp:// (Modbus TCP) and
+// rtuovertcp:// (RTU framing over a TCP socket).
+func Serve(ctx context.Context, listenURL string, handler modbus.RequestHandler) error {
+	u, err := url.Parse(listenURL)
+	if err != nil {
+		return err
+	}
+	switch u.Scheme {
+	case "tcp":
+		return serveTCP(ctx, u.Host, handler)
+	case "rtuovertcp":
+		return serveRTUOverTCP(ctx, u.Host, handler)
+	default:
+		return fmt.Errorf("unsupported listen URL: %s", listenURL)
+	}
+}
+
+func serveTCP(ctx context.Context, addr string, handler modbus.RequestHandler) error {
+	return serveConns(ctx, addr, func(conn net.Conn) error {
+		return ServeTCP(conn, handler)
+	})
+}
+
+func serveRTUOverTCP(ctx context.Context, addr string, handler modbus.RequestHandler) error {
+	return serveConns(ctx, addr, func(conn net.Conn) error {
+		return ServeRTU(conn, handler)
+	})
+}
+
+// serveConns accepts connections on addr until ctx is done, and serves each with serve. Open
+// connections are closed when ctx is done.
+func serveConns(ctx context.Context, addr string, serve func(conn net.Conn) error) error {
+	var lc net.ListenConfig
+	listener, err := lc.Listen(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	go func() {
+		<-ctx.Done()
+		_ = listener.Close()
+	}()
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		go func() {
+			stop := context.AfterFunc(ctx, func() {
+				_ = conn.Close()
+			})
+			defer func() {
+				stop()
+				_ = conn.Close()
+			}()
+			_ = serve(conn)
+		}()
+	}
+}
+
+// PTY is a pseudo-terminal pair. Clients open the RTU link at Name(), the slave side.
+type PTY struct {
+	master *os.File
+	slave  *os.File
+}
+
+func OpenPTY() (*PTY, error) {
+	master, slave, err := pty.Open()
+	if err != nil {
+		return nil, err
+	}
+	return &PTY{
+		master: master,
+		slave:  slave,
+	}, nil
+}
+
+func (p *PTY) Name() string {
+	return p.slave.Name()
+}
+
+// Serve runs an RTU server on the master side of the pseudo-terminal until ctx is done.
+func (p *PTY) Serve(ctx context.Context, handler modbus.RequestHandler) error {
+	go func() {
+		<-ctx.Done()
+		_ = p.Close()
+	}()
+	err := ServeRTU(p.master, handler)
+	if (ctx.Err() != nil) || errors.Is(err, os.ErrClosed) {
+		return nil
+	}
+	return err
+}
+
+func (p *PTY) Close() error {
+	return errors.Join(p.master.Close(), p.slave.Close())
+}
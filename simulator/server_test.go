@@ -0,0 +1,68 @@
+package simulator
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	prostar_pwm "github.com/ngyewch/prostar-pwm"
+	"github.com/simonvetter/modbus"
+)
+
+// TestServeDeviceInfo reads the device info, including the device identification objects, over
+// Modbus TCP and RTU over TCP.
+func TestServeDeviceInfo(t *testing.T) {
+	for _, scheme := range []string{"tcp", "rtuovertcp"} {
+		t.Run(scheme, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			l, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			url := scheme + "://" + l.Addr().String()
+			_ = l.Close()
+			handler := NewHandler(New(Config{UnitId: 1, SystemVoltage: 12, TimeScale: 1}))
+			go func() {
+				_ = Serve(ctx, url, handler)
+			}()
+
+			bus := prostar_pwm.NewModbusBus(modbus.ClientConfiguration{
+				URL:     url,
+				Timeout: 1 * time.Second,
+			})
+			defer func() {
+				_ = bus.Close()
+			}()
+			var r prostar_pwm.DeviceInfo
+			deadline := time.Now().Add(5 * time.Second)
+			for {
+				r, err = bus.Dev(1).ReadDeviceInfo()
+				if (err == nil) || time.Now().After(deadline) {
+					break
+				}
+				time.Sleep(100 * time.Millisecond)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (r.VendorName == nil) || (*r.VendorName != vendorName) {
+				t.Errorf("VendorName = %v, want %s", r.VendorName, vendorName)
+			}
+			if (r.ProductCode == nil) || (*r.ProductCode != productCode) {
+				t.Errorf("ProductCode = %v, want %s", r.ProductCode, productCode)
+			}
+			if (r.Revision == nil) || (r.SoftwareVersion == nil) || (*r.Revision != *r.SoftwareVersion) {
+				t.Errorf("Revision = %v, want the software version %v", r.Revision, r.SoftwareVersion)
+			}
+
+			// the client connection is usable again after the device identification request
+			_, err = bus.Dev(1).ReadChargerStatus()
+			if err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
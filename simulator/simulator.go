@@ -0,0 +1,457 @@
+package simulator
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	prostar_pwm "github.com/ngyewch/prostar-pwm"
+)
+
+const (
+	maxStep             = 10 * time.Second
+	historyStep         = 1 * time.Minute
+	nightCheckDuration  = 10 * time.Minute
+	batteryCapacity     = 80.0 // Ah
+	arrayRatedCurrent   = 12.0 // A
+	defaultLoadCurrent  = 2.5  // A
+	internalResistance  = 0.04 // Ω, per 12 V
+	startSOC            = 0.7
+	referenceVoltage12V = 12.0
+)
+
+type Config struct {
+	UnitId        uint8     // initial Modbus ID (Emodbus_id)
+	SystemVoltage int       // 12 or 24
+	TimeScale     float64   // simulated seconds per real second
+	StartTime     time.Time // simulated wall-clock time at start; defaults to time.Now()
+	LoadCurrent   float64   // A, load current while the load is connected
+	Seed          int64     // weather seed
+}
+
+type Simulator struct {
+	mutex  sync.Mutex
+	config Config
+	scale  float64
+	random *rand.Rand
+
+	unitId   uint8
+	eeprom   map[uint16]uint16
+	input    map[uint16]uint16
+	log      []uint16
+	logIndex int
+
+	now         time.Time
+	weather     float64
+	soc         float64
+	chargeState prostar_pwm.ChargeState
+	loadState   prostar_pwm.LoadState
+	stateTimer  float64
+	loadTimer   float64
+	equalize    bool
+	disconnect  bool
+
+	arrayVoltage   float64
+	arrayCurrent   float64
+	batteryVoltage float64
+	batteryCurrent float64
+	loadVoltage    float64
+	loadCurrent    float64
+	referenceV     float64
+	ambient        float64
+	batteryTemp    float64
+	heatsinkTemp   float64
+	alarm          uint32
+
+	hourmeter           float64
+	ahChargeResettable  float64
+	ahChargeTotal       float64
+	kWhChargeResettable float64
+	kWhChargeTotal      float64
+	ahLoadResettable    float64
+	ahLoadTotal         float64
+	vbMin               float64
+	vbMax               float64
+	vaMax               float64
+	lastEqualize        time.Time
+
+	daily dailyStats
+}
+
+type dailyStats struct {
+	alarm       uint32
+	vbMin       float64
+	vbMax       float64
+	ahCharge    float64
+	ahLoad      float64
+	vaMax       float64
+	absorption  float64
+	equalize    float64
+	float       float64
+	initialized bool
+	yearDay     int
+	year        int
+}
+
+func New(config Config) *Simulator {
+	if config.UnitId == 0 {
+		config.UnitId = 1
+	}
+	if config.SystemVoltage != 24 {
+		config.SystemVoltage = 12
+	}
+	if config.TimeScale <= 0 {
+		config.TimeScale = 1
+	}
+	if config.StartTime.IsZero() {
+		config.StartTime = time.Now()
+	}
+	if config.LoadCurrent <= 0 {
+		config.LoadCurrent = defaultLoadCurrent
+	}
+
+	s := &Simulator{
+		config:       config,
+		scale:        float64(config.SystemVoltage) / referenceVoltage12V,
+		random:       rand.New(rand.NewSource(config.Seed)),
+		unitId:       config.UnitId,
+		eeprom:       make(map[uint16]uint16),
+		input:        make(map[uint16]uint16),
+		log:          make([]uint16, logRecordCount*logRecordSize),
+		soc:          startSOC,
+		chargeState:  prostar_pwm.ChargeStateStart,
+		loadState:    prostar_pwm.LoadStateStart,
+		ambient:      25,
+		batteryTemp:  25,
+		heatsinkTemp: 25,
+		hourmeter:    float64(logRecordCount) * 24 * 1.5,
+		vbMin:        math.Inf(1),
+	}
+	s.initEEPROM()
+
+	// Run the model through the days before the start time so that the daily log and the
+	// counters hold a plausible history.
+	s.now = config.StartTime.Add(-logRecordCount * 24 * time.Hour)
+	s.lastEqualize = s.now
+	s.weather = s.nextWeather()
+	for s.now.Before(config.StartTime) {
+		s.step(historyStep.Seconds())
+	}
+	s.refresh()
+
+	return s
+}
+
+func (s *Simulator) UnitId() uint8 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.unitId
+}
+
+func (s *Simulator) Now() time.Time {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.now
+}
+
+// Advance moves the simulation forward by the given amount of simulated time.
+func (s *Simulator) Advance(d time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for d > 0 {
+		dt := min(d, maxStep)
+		s.step(dt.Seconds())
+		d -= dt
+	}
+	s.refresh()
+}
+
+// Run advances the simulation in real time, scaled by Config.TimeScale, until ctx is done.
+func (s *Simulator) Run(ctx context.Context) error {
+	ticker := time.NewTicker(1 * time.Second)
+	defer ticker.Stop()
+
+	last := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case t := <-ticker.C:
+			elapsed := t.Sub(last)
+			last = t
+			s.Advance(time.Duration(float64(elapsed) * s.config.TimeScale))
+		}
+	}
+}
+
+func (s *Simulator) nextWeather() float64 {
+	// mostly sunny, with the occasional run of overcast days
+	if s.random.Float64() < 0.25 {
+		return 0.15 + 0.35*s.random.Float64()
+	}
+	return 0.75 + 0.25*s.random.Float64()
+}
+
+func (s *Simulator) sun() float64 {
+	hour := float64(s.now.Hour()) + float64(s.now.Minute())/60 + float64(s.now.Second())/3600
+	if (hour < 6) || (hour > 18) {
+		return 0
+	}
+	return math.Sin(math.Pi*(hour-6)/12) * s.weather
+}
+
+func (s *Simulator) openCircuitVoltage() float64 {
+	var v float64
+	if s.soc < 0.9 {
+		v = 11.2 + 1.7*s.soc
+	} else {
+		v = 12.73 + (s.soc-0.9)*12.7
+	}
+	return v * s.scale
+}
+
+func (s *Simulator) temperatureCompensated(v float64) float64 {
+	t := s.batteryTemp
+	t = math.Min(t, float64(int16(s.eeprom[regMaxBatteryTempCompensationLimit])))
+	t = math.Max(t, float64(int16(s.eeprom[regMinBatteryTempCompensationLimit])))
+	v -= s.eepromFloat(regTemperatureCompensationCoefficient) * (t - 25)
+	limit := s.eepromFloat(regMaximumChargeVoltageReference)
+	if limit > 0 {
+		v = math.Min(v, limit)
+	}
+	return v
+}
+
+func (s *Simulator) setChargeState(state prostar_pwm.ChargeState) {
+	if s.chargeState != state {
+		s.chargeState = state
+		s.stateTimer = 0
+	}
+}
+
+func (s *Simulator) setLoadState(state prostar_pwm.LoadState) {
+	if s.loadState != state {
+		s.loadState = state
+		s.loadTimer = 0
+	}
+}
+
+func (s *Simulator) step(dt float64) {
+	s.now = s.now.Add(time.Duration(dt * float64(time.Second)))
+	s.rollDay()
+
+	sun := s.sun()
+	s.ambient = 20 + 10*sun
+	s.batteryTemp += (s.ambient - s.batteryTemp) * math.Min(1, dt/7200)
+
+	arrayOpenCircuit := 0.0
+	if sun > 0.01 {
+		arrayOpenCircuit = (17.5 + 3.5*sun) * s.scale
+	}
+	arrayAvailable := arrayRatedCurrent * sun
+	r := internalResistance * s.scale
+	ocv := s.openCircuitVoltage()
+	canCharge := arrayOpenCircuit > ocv+0.5*s.scale
+
+	s.stateTimer += dt
+	s.loadTimer += dt
+
+	days := time.Duration(s.eeprom[regDaysBetweenEQCycles]) * 24 * time.Hour
+	if (days > 0) && (s.now.Sub(s.lastEqualize) >= days) {
+		s.equalize = true
+	}
+
+	switch s.chargeState {
+	case prostar_pwm.ChargeStateStart:
+		if canCharge {
+			s.setChargeState(prostar_pwm.ChargeStateBulk)
+		} else {
+			s.setChargeState(prostar_pwm.ChargeStateNight)
+		}
+	case prostar_pwm.ChargeStateNight:
+		if canCharge {
+			s.setChargeState(prostar_pwm.ChargeStateStart)
+		}
+	case prostar_pwm.ChargeStateNightCheck:
+		if canCharge {
+			s.setChargeState(prostar_pwm.ChargeStateBulk)
+		} else if s.stateTimer >= nightCheckDuration.Seconds() {
+			s.setChargeState(prostar_pwm.ChargeStateNight)
+		}
+	default:
+		if !canCharge {
+			s.setChargeState(prostar_pwm.ChargeStateNightCheck)
+		} else if s.equalize && (s.chargeState != prostar_pwm.ChargeStateEqualize) {
+			s.setChargeState(prostar_pwm.ChargeStateEqualize)
+		}
+	}
+
+	regulation := s.temperatureCompensated(s.eepromFloat(regRegulationVoltage))
+	target := math.Inf(1)
+	switch s.chargeState {
+	case prostar_pwm.ChargeStateAbsorption:
+		target = regulation
+	case prostar_pwm.ChargeStateFloat:
+		target = s.temperatureCompensated(s.eepromFloat(regFloatVoltage))
+	case prostar_pwm.ChargeStateEqualize:
+		target = s.temperatureCompensated(s.eepromFloat(regEqualizeVoltage))
+	}
+
+	loadOn := (s.loadState == prostar_pwm.LoadStateLoadOn) || (s.loadState == prostar_pwm.LoadStateLVDWarning)
+	loadCurrent := 0.0
+	if loadOn {
+		loadCurrent = s.config.LoadCurrent
+	}
+
+	chargeCurrent := 0.0
+	switch s.chargeState {
+	case prostar_pwm.ChargeStateBulk, prostar_pwm.ChargeStateAbsorption, prostar_pwm.ChargeStateFloat, prostar_pwm.ChargeStateEqualize:
+		chargeCurrent = math.Min(arrayAvailable, s.eepromFloat(regChargeCurrentLimit))
+		if !math.IsInf(target, 1) {
+			chargeCurrent = math.Min(chargeCurrent, (target-ocv)/r+loadCurrent)
+		}
+		chargeCurrent = math.Max(chargeCurrent, 0)
+	}
+
+	net := chargeCurrent - loadCurrent
+	vb := ocv + net*r
+	s.referenceV = target
+	if math.IsInf(target, 1) {
+		s.referenceV = regulation
+	}
+
+	switch s.chargeState {
+	case prostar_pwm.ChargeStateBulk:
+		if vb >= regulation-0.01 {
+			s.setChargeState(prostar_pwm.ChargeStateAbsorption)
+		}
+	case prostar_pwm.ChargeStateAbsorption:
+		absorptionTime := float64(s.eeprom[regTimeBeforeEnteringFloat])
+		if s.daily.vbMin < s.eepromFloat(regVoltageTriggerForLowBatteryFloatTime) {
+			absorptionTime = float64(s.eeprom[regTimeBeforeEnteringFloatDueToLowBattery])
+		}
+		if s.stateTimer >= absorptionTime {
+			s.setChargeState(prostar_pwm.ChargeStateFloat)
+		}
+	case prostar_pwm.ChargeStateFloat:
+		if vb < s.eepromFloat(regVoltageToCancelFloat) {
+			s.setChargeState(prostar_pwm.ChargeStateBulk)
+		}
+	case prostar_pwm.ChargeStateEqualize:
+		if s.stateTimer >= float64(s.eeprom[regEqualizeTimeLimitAtEVEq]) {
+			s.equalize = false
+			s.lastEqualize = s.now
+			s.setChargeState(prostar_pwm.ChargeStateFloat)
+		}
+	}
+
+	lvd := s.eepromFloat(regLowVoltageDisconnect) - loadCurrent*s.eepromFloat(regLVDLoadCurrentCompensation)
+	switch s.loadState {
+	case prostar_pwm.LoadStateStart:
+		s.setLoadState(prostar_pwm.LoadStateLoadOn)
+	case prostar_pwm.LoadStateLoadOn:
+		if s.disconnect || (vb > s.eepromFloat(regLoadHighVoltageDisconnect)) {
+			s.setLoadState(prostar_pwm.LoadStateDisconnect)
+		} else if vb < lvd {
+			s.setLoadState(prostar_pwm.LoadStateLVDWarning)
+		}
+	case prostar_pwm.LoadStateLVDWarning:
+		if s.disconnect {
+			s.setLoadState(prostar_pwm.LoadStateDisconnect)
+		} else if vb >= lvd {
+			s.setLoadState(prostar_pwm.LoadStateLoadOn)
+		} else if s.loadTimer >= float64(s.eeprom[regLVDWarningTimeout]) {
+			s.setLoadState(prostar_pwm.LoadStateLVD)
+		}
+	case prostar_pwm.LoadStateLVD:
+		if s.disconnect {
+			s.setLoadState(prostar_pwm.LoadStateDisconnect)
+		} else if vb >= s.eepromFloat(regLowVoltageReconnect) {
+			s.setLoadState(prostar_pwm.LoadStateLoadOn)
+		}
+	case prostar_pwm.LoadStateDisconnect:
+		if !s.disconnect && (vb < s.eepromFloat(regLoadHighVoltageReconnect)) {
+			s.setLoadState(prostar_pwm.LoadStateLoadOn)
+		}
+	}
+
+	s.soc = math.Max(0, math.Min(1, s.soc+net*dt/3600/batteryCapacity))
+
+	s.batteryVoltage = vb
+	s.batteryCurrent = net
+	s.arrayCurrent = chargeCurrent
+	s.arrayVoltage = arrayOpenCircuit
+	if chargeCurrent > 0.01 {
+		s.arrayVoltage = vb + 0.2*s.scale
+	}
+	s.loadCurrent = loadCurrent
+	s.loadVoltage = 0
+	if loadOn {
+		s.loadVoltage = vb - 0.05
+	}
+	s.heatsinkTemp = s.ambient + 0.8*chargeCurrent + 0.3*loadCurrent
+
+	s.alarm = 0
+	if s.loadState == prostar_pwm.LoadStateLVD {
+		s.alarm |= 1 << 20
+	}
+	if chargeCurrent >= s.eepromFloat(regChargeCurrentLimit) {
+		s.alarm |= 1 << 6
+	}
+
+	s.hourmeter += dt / 3600
+	ah := chargeCurrent * dt / 3600
+	kWh := chargeCurrent * vb * dt / 3600 / 1000
+	s.ahChargeResettable += ah
+	s.ahChargeTotal += ah
+	s.kWhChargeResettable += kWh
+	s.kWhChargeTotal += kWh
+	s.ahLoadResettable += loadCurrent * dt / 3600
+	s.ahLoadTotal += loadCurrent * dt / 3600
+	s.vbMin = math.Min(s.vbMin, vb)
+	s.vbMax = math.Max(s.vbMax, vb)
+	s.vaMax = math.Max(s.vaMax, s.arrayVoltage)
+
+	s.daily.alarm |= s.alarm
+	s.daily.vbMin = math.Min(s.daily.vbMin, vb)
+	s.daily.vbMax = math.Max(s.daily.vbMax, vb)
+	s.daily.ahCharge += ah
+	s.daily.ahLoad += loadCurrent * dt / 3600
+	s.daily.vaMax = math.Max(s.daily.vaMax, s.arrayVoltage)
+	switch s.chargeState {
+	case prostar_pwm.ChargeStateAbsorption:
+		s.daily.absorption += dt
+	case prostar_pwm.ChargeStateEqualize:
+		s.daily.equalize += dt
+	case prostar_pwm.ChargeStateFloat:
+		s.daily.float += dt
+	}
+}
+
+func (s *Simulator) rollDay() {
+	if !s.daily.initialized {
+		s.daily = dailyStats{
+			vbMin:       math.Inf(1),
+			initialized: true,
+			yearDay:     s.now.YearDay(),
+			year:        s.now.Year(),
+		}
+		return
+	}
+	if (s.daily.yearDay == s.now.YearDay()) && (s.daily.year == s.now.Year()) {
+		return
+	}
+	s.appendLogRecord()
+	s.weather = s.nextWeather()
+	s.daily = dailyStats{
+		vbMin:       math.Inf(1),
+		initialized: true,
+		yearDay:     s.now.YearDay(),
+		year:        s.now.Year(),
+	}
+}
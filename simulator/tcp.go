@@ -0,0 +1,45 @@
+package simulator
+
+import (
+	"encoding/binary"
+	"io"
+
+	"github.com/simonvetter/modbus"
+)
+
+// ServeTCP reads Modbus TCP requests from rw and writes the responses back until rw returns an error.
+// Requests for unit IDs not served by the handler are answered with a gateway target exception, as a
+// TCP to RTU gateway would.
+func ServeTCP(rw io.ReadWriter, handler modbus.RequestHandler) error {
+	// transaction ID, protocol ID, length and unit ID
+	header := make([]byte, 7)
+	for {
+		_, err := io.ReadFull(rw, header)
+		if err != nil {
+			return err
+		}
+		length := int(binary.BigEndian.Uint16(header[4:6]))
+		if (binary.BigEndian.Uint16(header[2:4]) != 0) || (length < 2) || (length > 254) {
+			return modbus.ErrProtocolError
+		}
+		req := make([]byte, length-1)
+		_, err = io.ReadFull(rw, req)
+		if err != nil {
+			return err
+		}
+
+		unitId := header[6]
+		res, err := handlePDU(unitId, req, handler)
+		if err != nil {
+			res = exceptionPDU(req[0], err)
+		}
+		frame := append([]byte{}, header[:4]...)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(1+len(res)))
+		frame = append(frame, unitId)
+		frame = append(frame, res...)
+		_, err = rw.Write(frame)
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package prostar_pwm
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Snapshot holds every input register group, read one after the other between StartTime and
+// EndTime. A group that could not be read is nil and its error is in Errors, keyed by the group
+// name. With a tolerant Dev, a partially read group is kept and its *PartialReadError recorded.
+type Snapshot struct {
+	StartTime       time.Time
+	EndTime         time.Time
+	RawADCData      *RawADCData
+	FilteredADCData *FilteredADCData
+	TemperatureData *TemperatureData
+	ChargerStatus   *ChargerStatus
+	LoadStatus      *LoadStatus
+	MiscData        *MiscData
+	Errors          map[string]error
+}
+
+// Err returns the group errors joined, or nil if every group was read.
+func (s Snapshot) Err() error {
+	var errs []error
+	for _, group := range snapshotGroups {
+		if err, ok := s.Errors[group]; ok {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+var snapshotGroups = []string{"RawADCData", "FilteredADCData", "TemperatureData", "ChargerStatus", "LoadStatus", "MiscData"}
+
+func (dev *Dev) ReadSnapshot() (Snapshot, error) {
+	return dev.ReadSnapshotContext(context.Background())
+}
+
+// ReadSnapshotContext reads every input register group. Group errors are recorded in the snapshot
+// rather than returned; the error is only set if ctx ends the read.
+func (dev *Dev) ReadSnapshotContext(ctx context.Context) (Snapshot, error) {
+	s := Snapshot{
+		StartTime: time.Now(),
+		Errors:    make(map[string]error),
+	}
+	var err error
+	s.RawADCData, err = readSnapshotGroup(ctx, dev.ReadRawADCDataContext)
+	if err != nil {
+		s.Errors["RawADCData"] = err
+	}
+	s.FilteredADCData, err = readSnapshotGroup(ctx, dev.ReadFilteredADCDataContext)
+	if err != nil {
+		s.Errors["FilteredADCData"] = err
+	}
+	s.TemperatureData, err = readSnapshotGroup(ctx, dev.ReadTemperatureDataContext)
+	if err != nil {
+		s.Errors["TemperatureData"] = err
+	}
+	s.ChargerStatus, err = readSnapshotGroup(ctx, dev.ReadChargerStatusContext)
+	if err != nil {
+		s.Errors["ChargerStatus"] = err
+	}
+	s.LoadStatus, err = readSnapshotGroup(ctx, dev.ReadLoadStatusContext)
+	if err != nil {
+		s.Errors["LoadStatus"] = err
+	}
+	s.MiscData, err = readSnapshotGroup(ctx, dev.ReadMiscDataContext)
+	if err != nil {
+		s.Errors["MiscData"] = err
+	}
+	s.EndTime = time.Now()
+	return s, ctx.Err()
+}
+
+func readSnapshotGroup[T any](ctx context.Context, read func(ctx context.Context) (T, error)) (*T, error) {
+	v, err := read(ctx)
+	if (err != nil) && !errors.Is(err, ErrPartialRead) {
+		return nil, err
+	}
+	return &v, err
+}
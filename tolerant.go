@@ -0,0 +1,176 @@
+package prostar_pwm
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrPartialRead = errors.New("partial read")
+
+// PartialReadError is returned by a tolerant Dev when some fields could not be read. The struct
+// returned alongside holds every field that was read; the failed fields are nil.
+type PartialReadError struct {
+	Errors []*RegisterError
+}
+
+func (e *PartialReadError) Error() string {
+	var messages []string
+	for _, registerError := range e.Errors {
+		messages = append(messages, registerError.Error())
+	}
+	return fmt.Sprintf("%s: %s", ErrPartialRead, strings.Join(messages, "; "))
+}
+
+func (e *PartialReadError) Is(target error) bool {
+	return target == ErrPartialRead
+}
+
+func (e *PartialReadError) Unwrap() []error {
+	var errs []error
+	for _, registerError := range e.Errors {
+		errs = append(errs, registerError)
+	}
+	return errs
+}
+
+// Tolerant returns a Dev for the same device whose reads do not stop at the first failed register.
+// A block read that fails falls back to reading each field on its own, failed fields are left nil
+// and the partial result is returned with a *PartialReadError listing them. Context errors still end
+// the read.
+func (dev *Dev) Tolerant() *Dev {
+	d := *dev
+	d.tolerant = true
+	return &d
+}
+
+type readErrors struct {
+	tolerant bool
+	errs     []*RegisterError
+	stopped  error
+}
+
+func (dev *Dev) newReadErrors() *readErrors {
+	return &readErrors{
+		tolerant: dev.tolerant,
+	}
+}
+
+// add records err and returns nil if reading should continue with the next field, otherwise it
+// returns err.
+func (e *readErrors) add(err error) error {
+	if !e.tolerant {
+		return err
+	}
+	registerError, ok := err.(*RegisterError)
+	if !ok {
+		return err
+	}
+	e.errs = append(e.errs, registerError)
+	return nil
+}
+
+// merge adds the field errors of a partial read of another group.
+func (e *readErrors) merge(err error) error {
+	var partialReadError *PartialReadError
+	if !e.tolerant || !errors.As(err, &partialReadError) {
+		return err
+	}
+	e.errs = append(e.errs, partialReadError.Errors...)
+	return nil
+}
+
+func (e *readErrors) err() error {
+	if len(e.errs) == 0 {
+		return nil
+	}
+	return &PartialReadError{Errors: e.errs}
+}
+
+// readResult returns r with the partial read error, if any, or the zero value with the error that
+// stopped the read.
+func readResult[T any](e *readErrors, r T) (T, error) {
+	if e.stopped != nil {
+		var zero T
+		return zero, e.stopped
+	}
+	return r, e.err()
+}
+
+// fieldReader reads the fields of group from registers. A failed field is recorded in errs and read
+// as nil; once a failure stops the read (see readErrors.add), the remaining fields are skipped and
+// read as nil as well.
+type fieldReader struct {
+	errs      *readErrors
+	group     string
+	registers *Registers
+}
+
+func (e *readErrors) fields(group string, registers *Registers) *fieldReader {
+	return &fieldReader{
+		errs:      e,
+		group:     group,
+		registers: registers,
+	}
+}
+
+func readField[T any](f *fieldReader, field string, read func() (*T, error)) *T {
+	if f.errs.stopped != nil {
+		return nil
+	}
+	v, err := read()
+	if err != nil {
+		f.errs.stopped = f.errs.add(withField(f.group, field, err))
+		return nil
+	}
+	return v
+}
+
+func (f *fieldReader) Uint16(field string, addr uint16) *uint16 {
+	return readField(f, field, func() (*uint16, error) {
+		return f.registers.ReadUint16Ptr(addr)
+	})
+}
+
+func (f *fieldReader) Float16AsFloat32(field string, addr uint16) *float32 {
+	return readField(f, field, func() (*float32, error) {
+		return f.registers.ReadFloat16AsFloat32Ptr(addr)
+	})
+}
+
+func (f *fieldReader) Uint16AsFloat32(field string, addr uint16, divisor float32) *float32 {
+	return readField(f, field, func() (*float32, error) {
+		return f.registers.ReadUint16AsFloat32Ptr(addr, divisor)
+	})
+}
+
+func (f *fieldReader) Uint16AsInt16(field string, addr uint16) *int16 {
+	return readField(f, field, func() (*int16, error) {
+		return f.registers.ReadUint16AsInt16Ptr(addr)
+	})
+}
+
+func (f *fieldReader) Uint32(field string, addr uint16, wordOrdering WordOrdering) *uint32 {
+	return readField(f, field, func() (*uint32, error) {
+		return f.registers.ReadUint32Ptr(addr, wordOrdering)
+	})
+}
+
+func (f *fieldReader) Uint32AsFloat32(field string, addr uint16, wordOrdering WordOrdering, divisor float32) *float32 {
+	return readField(f, field, func() (*float32, error) {
+		return f.registers.ReadUint32AsFloat32Ptr(addr, wordOrdering, divisor)
+	})
+}
+
+// prefetch is Registers.Prefetch, except that a tolerant Dev falls back to per-register reads if
+// the block read fails.
+func (dev *Dev) prefetch(registers *Registers, addr uint16, quantity uint16) (*Registers, error) {
+	prefetched, err := registers.Prefetch(addr, quantity)
+	if err != nil {
+		if _, ok := err.(*RegisterError); ok && dev.tolerant {
+			return registers, nil
+		}
+		return nil, err
+	}
+	return prefetched, nil
+}
@@ -0,0 +1,55 @@
+package prostar_pwm
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/simonvetter/modbus"
+)
+
+func TestReadTolerant(t *testing.T) {
+	transport := newFakeTransport()
+	transport.registers[0x0023] = 0x4a00 // 12.0 V
+	transport.failing[0x0024] = modbus.ErrBadCRC
+	dev := New(transport, 1, &sync.Mutex{}).Tolerant()
+
+	r, err := dev.ReadChargerStatus()
+	var partialReadError *PartialReadError
+	if !errors.As(err, &partialReadError) {
+		t.Fatalf("err = %v, want a PartialReadError", err)
+	}
+	if (len(partialReadError.Errors) != 1) || (partialReadError.Errors[0].Group != "ChargerStatus") || (partialReadError.Errors[0].Field != "BatteryRegulatorReferenceVoltage") {
+		t.Errorf("errors = %v, want ChargerStatus.BatteryRegulatorReferenceVoltage only", partialReadError.Errors)
+	}
+	if (r.BatteryVoltage == nil) || (*r.BatteryVoltage != 12) {
+		t.Errorf("BatteryVoltage = %v, want 12", r.BatteryVoltage)
+	}
+	if r.BatteryRegulatorReferenceVoltage != nil {
+		t.Errorf("BatteryRegulatorReferenceVoltage = %v, want nil", *r.BatteryRegulatorReferenceVoltage)
+	}
+	if r.KWhChargeTotal == nil {
+		t.Errorf("KWhChargeTotal = nil, want the fields after the failed one to be read")
+	}
+}
+
+func TestReadNotTolerant(t *testing.T) {
+	transport := newFakeTransport()
+	transport.failing[0x0024] = modbus.ErrBadCRC
+	dev := New(transport, 1, &sync.Mutex{})
+
+	r, err := dev.ReadChargerStatus()
+	var registerError *RegisterError
+	if !errors.As(err, &registerError) || !errors.Is(err, ErrBadCRC) {
+		t.Fatalf("err = %v, want a RegisterError for a bad CRC", err)
+	}
+	if errors.Is(err, ErrPartialRead) {
+		t.Errorf("err = %v, want no partial read", err)
+	}
+	if r.BatteryVoltage != nil {
+		t.Errorf("BatteryVoltage = %v, want the zero value", *r.BatteryVoltage)
+	}
+	if transport.requests != 1 {
+		t.Errorf("%d requests, want 1", transport.requests)
+	}
+}
@@ -7,20 +7,26 @@ import (
 )
 
 func doChargeSettings(ctx context.Context, cmd *cli.Command) error {
-	dev, err := newDev(cmd)
+	dev, bus, err := newDev(cmd)
 	if err != nil {
 		return err
 	}
+	defer func() {
+		_ = bus.Close()
+	}()
 
-	result, err := dev.ReadChargeSettings()
+	result, err := dev.ReadChargeSettingsContext(ctx)
+	err = warnPartialRead(err)
 	if err != nil {
 		return err
 	}
 
-	err = dump(result)
+	err = output(cmd, result)
 	if err != nil {
 		return err
 	}
 
+	warnDIPSwitch(ctx, dev)
+
 	return nil
 }
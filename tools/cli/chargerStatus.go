@@ -7,17 +7,21 @@ import (
 )
 
 func doChargerStatus(ctx context.Context, cmd *cli.Command) error {
-	dev, err := newDev(cmd)
+	dev, bus, err := newDev(cmd)
 	if err != nil {
 		return err
 	}
+	defer func() {
+		_ = bus.Close()
+	}()
 
-	result, err := dev.ReadChargerStatus()
+	result, err := dev.ReadChargerStatusContext(ctx)
+	err = warnPartialRead(err)
 	if err != nil {
 		return err
 	}
 
-	err = dump(result)
+	err = output(cmd, result)
 	if err != nil {
 		return err
 	}
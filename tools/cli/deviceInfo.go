@@ -0,0 +1,30 @@
+package main
+
+import (
+	"context"
+
+	"github.com/urfave/cli/v3"
+)
+
+func doDeviceInfo(ctx context.Context, cmd *cli.Command) error {
+	dev, bus, err := newDev(cmd)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = bus.Close()
+	}()
+
+	result, err := dev.ReadDeviceInfoContext(ctx)
+	err = warnPartialRead(err)
+	if err != nil {
+		return err
+	}
+
+	err = output(cmd, result)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
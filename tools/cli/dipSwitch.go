@@ -0,0 +1,30 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	prostar_pwm "github.com/ngyewch/prostar-pwm"
+)
+
+func printDIPSwitchWarnings(dipSwitch *prostar_pwm.DIPSwitchDetails) {
+	if dipSwitch == nil {
+		return
+	}
+	for _, warning := range dipSwitch.Warnings() {
+		_, _ = fmt.Fprintf(os.Stderr, "warning: %s\n", warning)
+	}
+}
+
+// warnDIPSwitch prints a warning for each EEPROM setting overridden by the DIP switches. As the
+// warnings are advisory, a failure to read the DIP switches is only reported as a warning too.
+func warnDIPSwitch(ctx context.Context, dev *prostar_pwm.Dev) {
+	miscData, err := dev.ReadMiscDataContext(ctx)
+	if (err != nil) && !errors.Is(err, prostar_pwm.ErrPartialRead) {
+		_, _ = fmt.Fprintf(os.Stderr, "warning: DIP switches not read: %v\n", err)
+		return
+	}
+	printDIPSwitchWarnings(miscData.DIPSwitchDetails)
+}
@@ -1,28 +1,42 @@
 package main
 
 import (
-	"sync"
+	"errors"
+	"fmt"
+	"os"
 
 	prostar_pwm "github.com/ngyewch/prostar-pwm"
 	"github.com/urfave/cli/v3"
 )
 
-func newDev(cmd *cli.Command) (*prostar_pwm.Dev, error) {
-	client, err := newModbusClient(cmd, nil)
+// newDev returns the Dev for --modbus-unit-id, and the Bus to close when done with it.
+func newDev(cmd *cli.Command) (*prostar_pwm.Dev, *prostar_pwm.Bus, error) {
+	bus, err := newBus(cmd, nil)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	modbusUnitId := cmd.Uint(modbusUnitIdFlag.Name)
-
-	err = client.Open()
-	if err != nil {
-		return nil, err
+	dev := bus.Dev(uint8(cmd.Uint(modbusUnitIdFlag.Name)))
+	if cmd.Uint(modbusRetriesFlag.Name) > 0 {
+		policy := prostar_pwm.DefaultRetryPolicy()
+		policy.MaxRetries = int(cmd.Uint(modbusRetriesFlag.Name))
+		policy.Backoff = cmd.Duration(modbusRetryBackoffFlag.Name)
+		policy.MaxBackoff = max(policy.MaxBackoff, policy.Backoff)
+		dev = dev.WithRetryPolicy(policy)
+	}
+	if cmd.Bool(modbusTolerantFlag.Name) {
+		dev = dev.Tolerant()
 	}
 
-	var mutex sync.Mutex
-
-	dev := prostar_pwm.New(client, uint8(modbusUnitId), &mutex)
+	return dev, bus, nil
+}
 
-	return dev, nil
+// warnPartialRead prints a warning for a partial read (see --tolerant) so that the partial result can
+// still be output, and returns any other error.
+func warnPartialRead(err error) error {
+	if errors.Is(err, prostar_pwm.ErrPartialRead) {
+		_, _ = fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+		return nil
+	}
+	return err
 }
@@ -7,17 +7,21 @@ import (
 )
 
 func doLoadStatus(ctx context.Context, cmd *cli.Command) error {
-	dev, err := newDev(cmd)
+	dev, bus, err := newDev(cmd)
 	if err != nil {
 		return err
 	}
+	defer func() {
+		_ = bus.Close()
+	}()
 
-	result, err := dev.ReadLoadStatus()
+	result, err := dev.ReadLoadStatusContext(ctx)
+	err = warnPartialRead(err)
 	if err != nil {
 		return err
 	}
 
-	err = dump(result)
+	err = output(cmd, result)
 	if err != nil {
 		return err
 	}
@@ -2,22 +2,32 @@ package main
 
 import (
 	"context"
+	"fmt"
+	"os"
 
 	"github.com/urfave/cli/v3"
 )
 
 func doLoggedData(ctx context.Context, cmd *cli.Command) error {
-	dev, err := newDev(cmd)
+	dev, bus, err := newDev(cmd)
 	if err != nil {
 		return err
 	}
+	defer func() {
+		_ = bus.Close()
+	}()
 
-	result, err := dev.ReadLoggedData()
+	result, err := dev.ReadLoggedDataContext(ctx, func(recordsRead int, recordCount int) {
+		_, _ = fmt.Fprintf(os.Stderr, "\rreading logged data: %d/%d records", recordsRead, recordCount)
+		if recordsRead == recordCount {
+			_, _ = fmt.Fprintln(os.Stderr)
+		}
+	})
 	if err != nil {
 		return err
 	}
 
-	err = dump(result)
+	err = output(cmd, result)
 	if err != nil {
 		return err
 	}
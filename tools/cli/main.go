@@ -6,6 +6,7 @@ import (
 	"log"
 	"os"
 	"runtime/debug"
+	"time"
 
 	"github.com/urfave/cli/v3"
 )
@@ -16,7 +17,6 @@ var (
 	serialPortFlag = &cli.StringFlag{
 		Name:     "serial-port",
 		Usage:    "serial port",
-		Required: true,
 		Sources:  cli.EnvVars("SERIAL_PORT"),
 		Category: "Serial",
 	}
@@ -52,6 +52,12 @@ var (
 		Sources:  cli.EnvVars("STOP_BITS"),
 		Category: "Serial",
 	}
+	modbusURLFlag = &cli.StringFlag{
+		Name:     "url",
+		Usage:    "Modbus URL (rtu://, tcp://, rtuovertcp:// or rtuoverudp://), instead of --serial-port",
+		Sources:  cli.EnvVars("MODBUS_URL"),
+		Category: "Modbus",
+	}
 	modbusUnitIdFlag = &cli.UintFlag{
 		Name:    "modbus-unit-id",
 		Usage:   "ModBus unit ID",
@@ -65,11 +71,233 @@ var (
 		},
 		Category: "Modbus",
 	}
+	modbusTolerantFlag = &cli.BoolFlag{
+		Name:     "tolerant",
+		Usage:    "keep reading the remaining fields when a register read fails, leaving the failed fields empty",
+		Sources:  cli.EnvVars("MODBUS_TOLERANT"),
+		Category: "Modbus",
+	}
+	modbusRetriesFlag = &cli.UintFlag{
+		Name:     "retries",
+		Usage:    "number of times to retry a request that timed out or failed the CRC check",
+		Sources:  cli.EnvVars("MODBUS_RETRIES"),
+		Category: "Modbus",
+	}
+	modbusRetryBackoffFlag = &cli.DurationFlag{
+		Name:     "retry-backoff",
+		Usage:    "wait before the first retry, doubled for each further retry",
+		Value:    100 * time.Millisecond,
+		Sources:  cli.EnvVars("MODBUS_RETRY_BACKOFF"),
+		Category: "Modbus",
+	}
+	outputFlag = &cli.StringFlag{
+		Name:    "output",
+		Usage:   "output format (dump, json, yaml, csv or table)",
+		Value:   outputFormatDump,
+		Sources: cli.EnvVars("OUTPUT"),
+		Action: func(ctx context.Context, cmd *cli.Command, s string) error {
+			_, err := parseOutputFormat(s)
+			return err
+		},
+	}
+
+	simulateListenFlag = &cli.StringSliceFlag{
+		Name:  "listen",
+		Usage: "listen URL (tcp://host:port or rtuovertcp://host:port)",
+		Value: []string{"tcp://localhost:5020"},
+	}
+	simulatePTYFlag = &cli.BoolFlag{
+		Name:  "pty",
+		Usage: "also serve Modbus RTU on a pseudo-terminal",
+	}
+	simulateUnitIdFlag = &cli.UintSliceFlag{
+		Name:  "unit-id",
+		Usage: "Modbus unit ID of a simulated controller (repeat for several controllers)",
+		Value: []uint{1},
+	}
+	simulateSystemVoltageFlag = &cli.UintFlag{
+		Name:  "system-voltage",
+		Usage: "battery system voltage (12 or 24)",
+		Value: 12,
+		Action: func(ctx context.Context, cmd *cli.Command, v uint) error {
+			if (v != 12) && (v != 24) {
+				return fmt.Errorf("invalid system-voltage: %d", v)
+			}
+			return nil
+		},
+	}
+	simulateTimeScaleFlag = &cli.FloatFlag{
+		Name:  "time-scale",
+		Usage: "simulated seconds per real second",
+		Value: 1,
+	}
+
+	watchIntervalFlag = &cli.DurationFlag{
+		Name:  "interval",
+		Usage: "poll interval",
+		Value: 5 * time.Second,
+		Action: func(ctx context.Context, cmd *cli.Command, v time.Duration) error {
+			if v <= 0 {
+				return fmt.Errorf("invalid interval: %s", v)
+			}
+			return nil
+		},
+	}
+	watchGroupFlag = &cli.StringSliceFlag{
+		Name:  "group",
+		Usage: "group to poll (raw-adc-data, filtered-adc-data, temperature-data, charger-status, load-status, misc-data or statistics)",
+		Value: []string{"filtered-adc-data", "charger-status", "load-status"},
+	}
+	serveMetricsListenFlag = &cli.StringFlag{
+		Name:  "listen",
+		Usage: "HTTP listen address",
+		Value: ":9815",
+	}
+	serveMetricsIntervalFlag = &cli.DurationFlag{
+		Name:  "interval",
+		Usage: "poll interval",
+		Value: 15 * time.Second,
+		Action: func(ctx context.Context, cmd *cli.Command, v time.Duration) error {
+			if v <= 0 {
+				return fmt.Errorf("invalid interval: %s", v)
+			}
+			return nil
+		},
+	}
+	mqttBrokerFlag = &cli.StringFlag{
+		Name:     "broker",
+		Usage:    "MQTT broker URL",
+		Value:    "tcp://localhost:1883",
+		Sources:  cli.EnvVars("MQTT_BROKER"),
+		Category: "MQTT",
+	}
+	mqttClientIdFlag = &cli.StringFlag{
+		Name:     "client-id",
+		Usage:    "MQTT client ID (default: node ID)",
+		Sources:  cli.EnvVars("MQTT_CLIENT_ID"),
+		Category: "MQTT",
+	}
+	mqttUsernameFlag = &cli.StringFlag{
+		Name:     "username",
+		Usage:    "MQTT username",
+		Sources:  cli.EnvVars("MQTT_USERNAME"),
+		Category: "MQTT",
+	}
+	mqttPasswordFlag = &cli.StringFlag{
+		Name:     "password",
+		Usage:    "MQTT password",
+		Sources:  cli.EnvVars("MQTT_PASSWORD"),
+		Category: "MQTT",
+	}
+	mqttNodeIdFlag = &cli.StringFlag{
+		Name:  "node-id",
+		Usage: "node ID used in topics and Home Assistant unique IDs (default: prostar_pwm_<modbus-unit-id>)",
+	}
+	mqttTopicPrefixFlag = &cli.StringFlag{
+		Name:  "topic-prefix",
+		Usage: "topic prefix; readings are published to <topic-prefix>/<group> (default: prostar-pwm/<node-id>)",
+	}
+	mqttDiscoveryFlag = &cli.BoolFlag{
+		Name:     "discovery",
+		Usage:    "publish Home Assistant discovery configs",
+		Value:    true,
+		Category: "Home Assistant",
+	}
+	mqttDiscoveryPrefixFlag = &cli.StringFlag{
+		Name:     "discovery-prefix",
+		Usage:    "Home Assistant discovery prefix",
+		Value:    "homeassistant",
+		Category: "Home Assistant",
+	}
+	mqttIntervalFlag = &cli.DurationFlag{
+		Name:  "interval",
+		Usage: "poll interval",
+		Value: 30 * time.Second,
+		Action: func(ctx context.Context, cmd *cli.Command, v time.Duration) error {
+			if v <= 0 {
+				return fmt.Errorf("invalid interval: %s", v)
+			}
+			return nil
+		},
+	}
+	mqttGroupFlag = &cli.StringSliceFlag{
+		Name:  "group",
+		Usage: "group to publish (raw-adc-data, filtered-adc-data, temperature-data, charger-status, load-status, misc-data or statistics)",
+		Value: []string{"filtered-adc-data", "temperature-data", "charger-status", "load-status", "misc-data"},
+	}
+	settingsFormatFlag = &cli.StringFlag{
+		Name:  "format",
+		Usage: "file format (yaml or json; default: from the file extension, else yaml)",
+	}
+	settingsDryRunFlag = &cli.BoolFlag{
+		Name:  "dry-run",
+		Usage: "show the changes without writing them",
+	}
+	settingsIncludeModbusIdFlag = &cli.BoolFlag{
+		Name:  "include-modbus-id",
+		Usage: "also write the Modbus ID from the file",
+	}
+	settingsSystemVoltageFlag = &cli.UintFlag{
+		Name:     "system-voltage",
+		Usage:    "battery system voltage (12 or 24), used to validate the settings",
+		Sources:  cli.EnvVars("SYSTEM_VOLTAGE"),
+		Required: true,
+		Action: func(ctx context.Context, cmd *cli.Command, v uint) error {
+			if (v != 12) && (v != 24) {
+				return fmt.Errorf("invalid system-voltage: %d", v)
+			}
+			return nil
+		},
+	}
+	settingsModelFlag = &cli.StringFlag{
+		Name:     "model",
+		Usage:    "controller model (ps-15 or ps-30), used to validate the charge current limit",
+		Sources:  cli.EnvVars("PROSTAR_MODEL"),
+		Required: true,
+		Action: func(ctx context.Context, cmd *cli.Command, v string) error {
+			_, err := modelMaxChargeCurrent(v)
+			return err
+		},
+	}
+	settingsYesFlag = &cli.BoolFlag{
+		Name:  "yes",
+		Usage: "write without asking for confirmation",
+	}
+	presetsFileFlag = &cli.StringFlag{
+		Name:    "presets-file",
+		Usage:   "YAML or JSON file with custom presets",
+		Sources: cli.EnvVars("PRESETS_FILE"),
+	}
+	scanTimeoutFlag = &cli.DurationFlag{
+		Name:  "timeout",
+		Usage: "time to wait for each unit ID to answer",
+		Value: 200 * time.Millisecond,
+	}
+	scanFirstFlag = &cli.UintFlag{
+		Name:  "first",
+		Usage: "first unit ID to probe",
+		Value: 1,
+	}
+	scanLastFlag = &cli.UintFlag{
+		Name:  "last",
+		Usage: "last unit ID to probe",
+		Value: 247,
+	}
+	setModbusIdWaitFlag = &cli.DurationFlag{
+		Name:  "wait",
+		Usage: "time to wait for the device to answer at the new unit ID",
+		Value: 10 * time.Second,
+	}
 
 	app = &cli.Command{
 		Name:  "prostar-pwm",
 		Usage: "ProStar PWM CLI",
 		Commands: []*cli.Command{
+			{
+				Name:   "device-info",
+				Usage:  "device info",
+				Action: doDeviceInfo,
+			},
 			{
 				Name:   "raw-adc-data",
 				Usage:  "raw ADC data",
@@ -100,6 +328,16 @@ var (
 				Usage:  "misc data",
 				Action: doMiscData,
 			},
+			{
+				Name:   "power",
+				Usage:  "array, load and battery power, charge efficiency and temperature-compensated regulation voltage",
+				Action: doPower,
+			},
+			{
+				Name:   "snapshot",
+				Usage:  "all input register groups with timestamps",
+				Action: doSnapshot,
+			},
 			{
 				Name:   "charge-settings",
 				Usage:  "charge settings",
@@ -130,6 +368,125 @@ var (
 				Usage:  "logged data",
 				Action: doLoggedData,
 			},
+			{
+				Name:  "settings",
+				Usage: "settings backup and restore",
+				Commands: []*cli.Command{
+					{
+						Name:      "export",
+						Usage:     "export settings to a file",
+						ArgsUsage: "[file]",
+						Action:    doSettingsExport,
+						Flags: []cli.Flag{
+							settingsFormatFlag,
+						},
+					},
+					{
+						Name:      "import",
+						Usage:     "import settings from a file, writing only changed registers",
+						ArgsUsage: "file",
+						Action:    doSettingsImport,
+						Flags: []cli.Flag{
+							settingsSystemVoltageFlag,
+							settingsModelFlag,
+							settingsDryRunFlag,
+							settingsIncludeModbusIdFlag,
+						},
+					},
+					{
+						Name:   "presets",
+						Usage:  "list battery presets",
+						Action: doSettingsPresets,
+						Flags: []cli.Flag{
+							presetsFileFlag,
+						},
+					},
+					{
+						Name:      "apply-preset",
+						Usage:     "apply a battery preset, showing the changes first",
+						ArgsUsage: "name",
+						Description: "The built-in presets set the charge and equalization voltages and times, the temperature\n" +
+							"compensation, HVD/HVR and the load LVD/LVR and HVD/HVR. The settings that the operator's\n" +
+							"manual does not give per battery type are left as they are: absorption extension, float\n" +
+							"cancel and exit, the equalization time above regulation, the charge voltage limits, the\n" +
+							"temperature compensation limits, and the LVD current compensation and warning timeout.",
+						Action: doSettingsApplyPreset,
+						Flags: []cli.Flag{
+							presetsFileFlag,
+							settingsSystemVoltageFlag,
+							settingsModelFlag,
+							settingsDryRunFlag,
+							settingsYesFlag,
+						},
+					},
+				},
+			},
+			{
+				Name:   "scan",
+				Usage:  "find controllers by probing unit IDs",
+				Action: doScan,
+				Flags: []cli.Flag{
+					scanTimeoutFlag,
+					scanFirstFlag,
+					scanLastFlag,
+				},
+			},
+			{
+				Name:      "set-modbus-id",
+				Usage:     "change the Modbus ID of the device and confirm it answers at the new unit ID",
+				ArgsUsage: "unit-id",
+				Action:    doSetModbusId,
+				Flags: []cli.Flag{
+					setModbusIdWaitFlag,
+				},
+			},
+			{
+				Name:   "watch",
+				Usage:  "poll groups continuously (JSON lines with --output json, otherwise a refreshing table)",
+				Action: doWatch,
+				Flags: []cli.Flag{
+					watchIntervalFlag,
+					watchGroupFlag,
+				},
+			},
+			{
+				Name:   "serve-metrics",
+				Usage:  "serve Prometheus metrics",
+				Action: doServeMetrics,
+				Flags: []cli.Flag{
+					serveMetricsListenFlag,
+					serveMetricsIntervalFlag,
+				},
+			},
+			{
+				Name:   "mqtt",
+				Usage:  "publish readings to MQTT, with Home Assistant discovery",
+				Action: doMQTT,
+				Flags: []cli.Flag{
+					mqttBrokerFlag,
+					mqttClientIdFlag,
+					mqttUsernameFlag,
+					mqttPasswordFlag,
+					mqttNodeIdFlag,
+					mqttTopicPrefixFlag,
+					mqttDiscoveryFlag,
+					mqttDiscoveryPrefixFlag,
+					mqttIntervalFlag,
+					mqttGroupFlag,
+				},
+			},
+			{
+				Name:   "simulate",
+				Usage:  "run a ProStar PWM simulator",
+				Action: doSimulate,
+				Flags: []cli.Flag{
+					simulateListenFlag,
+					simulatePTYFlag,
+					simulateUnitIdFlag,
+					simulateSystemVoltageFlag,
+					simulateTimeScaleFlag,
+				},
+			},
 		},
 		Flags: []cli.Flag{
 			serialPortFlag,
@@ -137,7 +494,12 @@ var (
 			dataBitsFlag,
 			parityFlag,
 			stopBitsFlag,
+			modbusURLFlag,
 			modbusUnitIdFlag,
+			modbusTolerantFlag,
+			modbusRetriesFlag,
+			modbusRetryBackoffFlag,
+			outputFlag,
 		},
 	}
 )
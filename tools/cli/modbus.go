@@ -5,6 +5,7 @@ import (
 	"strings"
 	"time"
 
+	prostar_pwm "github.com/ngyewch/prostar-pwm"
 	"github.com/simonvetter/modbus"
 	"github.com/urfave/cli/v3"
 )
@@ -22,39 +23,70 @@ func parseParity(s string) (uint, error) {
 	return 0, fmt.Errorf("invalid parity: %s", s)
 }
 
-func newModbusClient(cmd *cli.Command, configurer func(cfg *modbus.ClientConfiguration)) (*modbus.ModbusClient, error) {
+func modbusURL(cmd *cli.Command) (string, error) {
 	serialPort := cmd.String(serialPortFlag.Name)
-	baudRate := cmd.Uint(baudRateFlag.Name)
-	dataBits := cmd.Uint(dataBitsFlag.Name)
-	parityString := cmd.String(parityFlag.Name)
-	stopBits := cmd.Uint(stopBitsFlag.Name)
+	u := cmd.String(modbusURLFlag.Name)
+	if (serialPort != "") && (u != "") {
+		return "", fmt.Errorf("%s and %s are mutually exclusive", serialPortFlag.Name, modbusURLFlag.Name)
+	}
+	if u == "" {
+		if serialPort == "" {
+			return "", fmt.Errorf("either %s or %s must be set", serialPortFlag.Name, modbusURLFlag.Name)
+		}
+		return "rtu://" + serialPort, nil
+	}
+	scheme, _, _ := strings.Cut(u, "://")
+	switch scheme {
+	case "rtu", "tcp", "rtuovertcp", "rtuoverudp":
+		return u, nil
+	}
+	return "", fmt.Errorf("unsupported %s: %s", modbusURLFlag.Name, u)
+}
 
-	parity, err := parseParity(parityString)
+func modbusClientConfiguration(cmd *cli.Command, configurer func(cfg *modbus.ClientConfiguration)) (modbus.ClientConfiguration, error) {
+	u, err := modbusURL(cmd)
 	if err != nil {
-		return nil, err
+		return modbus.ClientConfiguration{}, err
 	}
 
-	config := &modbus.ClientConfiguration{
-		URL:      "rtu://" + serialPort,
-		Speed:    baudRate,
-		DataBits: dataBits,
-		Parity:   parity,
-		StopBits: stopBits,
-		Timeout:  1 * time.Second,
+	config := modbus.ClientConfiguration{
+		URL:     u,
+		Timeout: 1 * time.Second,
+	}
+
+	scheme, _, _ := strings.Cut(u, "://")
+	switch scheme {
+	case "rtu":
+		parity, err := parseParity(cmd.String(parityFlag.Name))
+		if err != nil {
+			return modbus.ClientConfiguration{}, err
+		}
+		config.Speed = cmd.Uint(baudRateFlag.Name)
+		config.DataBits = cmd.Uint(dataBitsFlag.Name)
+		config.Parity = parity
+		config.StopBits = cmd.Uint(stopBitsFlag.Name)
+	case "rtuovertcp", "rtuoverudp":
+		// used for inter-frame timing on the serial side of the gateway
+		config.Speed = cmd.Uint(baudRateFlag.Name)
 	}
 	if configurer != nil {
-		configurer(config)
+		configurer(&config)
 	}
 
-	client, err := modbus.NewClient(config)
+	return config, nil
+}
+
+func newBus(cmd *cli.Command, configurer func(cfg *modbus.ClientConfiguration)) (*prostar_pwm.Bus, error) {
+	config, err := modbusClientConfiguration(cmd, configurer)
 	if err != nil {
 		return nil, err
 	}
 
-	err = client.Open()
+	bus := prostar_pwm.NewModbusBus(config)
+	err = bus.Open()
 	if err != nil {
 		return nil, err
 	}
 
-	return client, nil
+	return bus, nil
 }
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"reflect"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	prostar_pwm "github.com/ngyewch/prostar-pwm"
+	"github.com/urfave/cli/v3"
+)
+
+const (
+	mqttPayloadOnline  = "online"
+	mqttPayloadOffline = "offline"
+)
+
+type mqttPublisher struct {
+	client          mqtt.Client
+	topicPrefix     string
+	nodeId          string
+	discovery       bool
+	discoveryPrefix string
+	groups          []pollGroup
+	discovering     atomic.Bool
+}
+
+func (p *mqttPublisher) availabilityTopic() string {
+	return p.topicPrefix + "/availability"
+}
+
+func (p *mqttPublisher) stateTopic(group pollGroup) string {
+	return p.topicPrefix + "/" + group.Name
+}
+
+func (p *mqttPublisher) publish(topic string, retained bool, payload any) error {
+	return waitToken(p.client.Publish(topic, 1, retained, payload), "publish to "+topic)
+}
+
+func waitToken(token mqtt.Token, action string) error {
+	if !token.WaitTimeout(10 * time.Second) {
+		return fmt.Errorf("%s timed out", action)
+	}
+	if token.Error() != nil {
+		return fmt.Errorf("%s: %w", action, token.Error())
+	}
+	return nil
+}
+
+func (p *mqttPublisher) onConnect(client mqtt.Client) {
+	log.Printf("connected to MQTT broker")
+	err := p.publish(p.availabilityTopic(), true, mqttPayloadOnline)
+	if err != nil {
+		log.Printf("%v", err)
+	}
+	if !p.discovery {
+		return
+	}
+	p.publishDiscovery()
+	// Home Assistant announces itself on <prefix>/status after a restart; discovery configs are
+	// re-sent so that entities come back even if the retained configs were lost. Message handlers
+	// must not block the client, so the configs are published from another goroutine.
+	topic := p.discoveryPrefix + "/status"
+	err = waitToken(client.Subscribe(topic, 1, func(client mqtt.Client, msg mqtt.Message) {
+		if string(msg.Payload()) == mqttPayloadOnline {
+			go p.publishDiscovery()
+		}
+	}), "subscribe to "+topic)
+	if err != nil {
+		log.Printf("%v", err)
+	}
+}
+
+// publishDiscovery publishes the discovery configs, unless they are already being published.
+func (p *mqttPublisher) publishDiscovery() {
+	if !p.discovering.CompareAndSwap(false, true) {
+		return
+	}
+	defer p.discovering.Store(false)
+
+	for _, entity := range p.discoveryEntities() {
+		payload, err := json.Marshal(entity.Config)
+		if err != nil {
+			log.Printf("%v", err)
+			continue
+		}
+		topic := fmt.Sprintf("%s/%s/%s/%s/config", p.discoveryPrefix, entity.Component, p.nodeId, entity.ObjectId)
+		err = p.publish(topic, true, payload)
+		if err != nil {
+			log.Printf("%v", err)
+		}
+	}
+}
+
+func (p *mqttPublisher) poll(ctx context.Context, dev *prostar_pwm.Dev) {
+	for _, group := range p.groups {
+		result, err := group.Read(ctx, dev)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("%s: %v", group.Name, err)
+			}
+			if !errors.Is(err, prostar_pwm.ErrPartialRead) {
+				continue
+			}
+		}
+		payload, err := json.Marshal(normalize(reflect.ValueOf(result)))
+		if err != nil {
+			log.Printf("%s: %v", group.Name, err)
+			continue
+		}
+		if !p.client.IsConnectionOpen() {
+			// the client is reconnecting in the background; drop this reading rather than queue it
+			continue
+		}
+		err = p.publish(p.stateTopic(group), false, payload)
+		if err != nil {
+			log.Printf("%s: %v", group.Name, err)
+		}
+	}
+}
+
+type mqttDiscoveryEntity struct {
+	Component string
+	ObjectId  string
+	Config    map[string]any
+}
+
+func (p *mqttPublisher) discoveryEntities() []mqttDiscoveryEntity {
+	device := map[string]any{
+		"identifiers":  []string{p.nodeId},
+		"name":         "ProStar PWM " + p.nodeId,
+		"manufacturer": "Morningstar",
+		"model":        "ProStar PWM",
+	}
+
+	var entities []mqttDiscoveryEntity
+	add := func(component string, group string, objectId string, name string, config map[string]any) {
+		for _, g := range p.groups {
+			if g.Name != group {
+				continue
+			}
+			config["name"] = name
+			config["unique_id"] = p.nodeId + "_" + objectId
+			config["object_id"] = p.nodeId + "_" + objectId
+			config["state_topic"] = p.stateTopic(g)
+			config["availability_topic"] = p.availabilityTopic()
+			config["payload_available"] = mqttPayloadOnline
+			config["payload_not_available"] = mqttPayloadOffline
+			config["device"] = device
+			if _, ok := config["json_attributes_template"]; ok {
+				config["json_attributes_topic"] = p.stateTopic(g)
+			}
+			entities = append(entities, mqttDiscoveryEntity{
+				Component: component,
+				ObjectId:  objectId,
+				Config:    config,
+			})
+			return
+		}
+	}
+	measurement := func(field string, deviceClass string, unit string) map[string]any {
+		return map[string]any{
+			"value_template":      fmt.Sprintf("{{ value_json.%s }}", field),
+			"device_class":        deviceClass,
+			"unit_of_measurement": unit,
+			"state_class":         "measurement",
+		}
+	}
+	counter := func(field string, deviceClass string, unit string, stateClass string) map[string]any {
+		config := map[string]any{
+			"value_template":      fmt.Sprintf("{{ value_json.%s }}", field),
+			"unit_of_measurement": unit,
+			"state_class":         stateClass,
+		}
+		if deviceClass != "" {
+			config["device_class"] = deviceClass
+		}
+		return config
+	}
+	enum := func(field string, options []string) map[string]any {
+		return map[string]any{
+			"value_template": fmt.Sprintf("{{ value_json.%s }}", field),
+			"device_class":   "enum",
+			"options":        options,
+		}
+	}
+	problem := func(field string) map[string]any {
+		return map[string]any{
+			"value_template":           fmt.Sprintf("{{ 'ON' if (value_json.%s or []) | length > 0 else 'OFF' }}", field),
+			"device_class":             "problem",
+			"json_attributes_template": fmt.Sprintf("{{ {'active': value_json.%s} | tojson }}", field),
+		}
+	}
+
+	var chargeStates []string
+	for state := prostar_pwm.ChargeStateStart; state <= prostar_pwm.ChargeStateEqualize; state++ {
+		chargeStates = append(chargeStates, state.String())
+	}
+	var loadStates []string
+	for state := prostar_pwm.LoadStateStart; state <= prostar_pwm.LoadStateOverride; state++ {
+		loadStates = append(loadStates, state.String())
+	}
+
+	add("sensor", "filtered-adc-data", "array_voltage", "Array voltage", measurement("ArrayVoltage", "voltage", "V"))
+	add("sensor", "filtered-adc-data", "array_current", "Array current", measurement("ArrayCurrent", "current", "A"))
+	add("sensor", "filtered-adc-data", "battery_current", "Battery current", measurement("BatteryCurrent", "current", "A"))
+	add("sensor", "filtered-adc-data", "load_voltage", "Load voltage", measurement("LoadVoltage", "voltage", "V"))
+	add("sensor", "filtered-adc-data", "load_current", "Load current", measurement("LoadCurrent", "current", "A"))
+	add("sensor", "temperature-data", "heatsink_temperature", "Heatsink temperature", measurement("Heatsink", "temperature", "°C"))
+	add("sensor", "temperature-data", "battery_temperature", "Battery temperature", measurement("Battery", "temperature", "°C"))
+	add("sensor", "temperature-data", "ambient_temperature", "Ambient temperature", measurement("Ambient", "temperature", "°C"))
+	add("sensor", "temperature-data", "remote_temperature", "Remote temperature", measurement("Remote", "temperature", "°C"))
+	add("sensor", "charger-status", "battery_voltage", "Battery voltage", measurement("BatteryVoltage", "voltage", "V"))
+	add("sensor", "charger-status", "battery_regulator_reference_voltage", "Battery regulator reference voltage", measurement("BatteryRegulatorReferenceVoltage", "voltage", "V"))
+	add("sensor", "charger-status", "charge_state", "Charge state", enum("ChargeState", chargeStates))
+	add("sensor", "charger-status", "ah_charge_resettable", "Ah charge (resettable)", counter("AhChargeResettable", "", "Ah", "total"))
+	add("sensor", "charger-status", "ah_charge_total", "Ah charge (total)", counter("AhChargeTotal", "", "Ah", "total_increasing"))
+	add("sensor", "charger-status", "kwh_charge_resettable", "kWh charge (resettable)", counter("KWhChargeResettable", "energy", "kWh", "total"))
+	add("sensor", "charger-status", "kwh_charge_total", "kWh charge (total)", counter("KWhChargeTotal", "energy", "kWh", "total_increasing"))
+	add("binary_sensor", "charger-status", "array_fault", "Array fault", problem("ArrayFault"))
+	add("sensor", "load-status", "load_state", "Load state", enum("LoadState", loadStates))
+	add("sensor", "load-status", "ah_load_resettable", "Ah load (resettable)", counter("AhLoadResettable", "", "Ah", "total"))
+	add("sensor", "load-status", "ah_load_total", "Ah load (total)", counter("AhLoadTotal", "", "Ah", "total_increasing"))
+	add("binary_sensor", "load-status", "load_fault", "Load fault", problem("LoadFault"))
+	add("binary_sensor", "misc-data", "alarm", "Alarm", problem("Alarm"))
+	add("sensor", "misc-data", "hourmeter", "Hourmeter", counter("Hourmeter", "duration", "h", "total_increasing"))
+
+	return entities
+}
+
+func doMQTT(ctx context.Context, cmd *cli.Command) error {
+	ctx, cancel := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	var groups []pollGroup
+	for _, name := range cmd.StringSlice(mqttGroupFlag.Name) {
+		group, err := findPollGroup(name)
+		if err != nil {
+			return err
+		}
+		groups = append(groups, group)
+	}
+
+	dev, bus, err := newDev(cmd)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = bus.Close()
+	}()
+
+	nodeId := cmd.String(mqttNodeIdFlag.Name)
+	if nodeId == "" {
+		nodeId = fmt.Sprintf("prostar_pwm_%d", cmd.Uint(modbusUnitIdFlag.Name))
+	}
+	topicPrefix := cmd.String(mqttTopicPrefixFlag.Name)
+	if topicPrefix == "" {
+		topicPrefix = "prostar-pwm/" + nodeId
+	}
+	clientId := cmd.String(mqttClientIdFlag.Name)
+	if clientId == "" {
+		clientId = nodeId
+	}
+
+	publisher := &mqttPublisher{
+		topicPrefix:     topicPrefix,
+		nodeId:          nodeId,
+		discovery:       cmd.Bool(mqttDiscoveryFlag.Name),
+		discoveryPrefix: cmd.String(mqttDiscoveryPrefixFlag.Name),
+		groups:          groups,
+	}
+
+	opts := mqtt.NewClientOptions().
+		AddBroker(cmd.String(mqttBrokerFlag.Name)).
+		SetClientID(clientId).
+		SetUsername(cmd.String(mqttUsernameFlag.Name)).
+		SetPassword(cmd.String(mqttPasswordFlag.Name)).
+		SetWill(publisher.availabilityTopic(), mqttPayloadOffline, 1, true).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetConnectRetryInterval(5 * time.Second).
+		SetMaxReconnectInterval(1 * time.Minute).
+		SetOnConnectHandler(publisher.onConnect).
+		SetConnectionLostHandler(func(client mqtt.Client, err error) {
+			log.Printf("lost connection to MQTT broker: %v", err)
+		}).
+		SetReconnectingHandler(func(client mqtt.Client, opts *mqtt.ClientOptions) {
+			log.Printf("reconnecting to MQTT broker")
+		})
+	publisher.client = mqtt.NewClient(opts)
+	// with SetConnectRetry the token only completes once connected, so it is not waited upon
+	publisher.client.Connect()
+	defer func() {
+		if publisher.client.IsConnectionOpen() {
+			err := publisher.publish(publisher.availabilityTopic(), true, mqttPayloadOffline)
+			if err != nil {
+				log.Printf("%v", err)
+			}
+		}
+		publisher.client.Disconnect(250)
+	}()
+
+	ticker := time.NewTicker(cmd.Duration(mqttIntervalFlag.Name))
+	defer ticker.Stop()
+	for {
+		publisher.poll(ctx, dev)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	mochi "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/hooks/auth"
+	"github.com/mochi-mqtt/server/v2/listeners"
+	"github.com/mochi-mqtt/server/v2/packets"
+	prostar_pwm "github.com/ngyewch/prostar-pwm"
+	"github.com/ngyewch/prostar-pwm/simulator"
+	"github.com/simonvetter/modbus"
+)
+
+// TestMQTTPublish runs the publisher against an in-process broker and a simulated controller.
+func TestMQTTPublish(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	modbusAddr := freeAddr(t)
+	handler := simulator.NewHandler(simulator.New(simulator.Config{
+		UnitId:        1,
+		SystemVoltage: 12,
+		TimeScale:     1,
+	}))
+	go func() {
+		_ = simulator.Serve(ctx, "tcp://"+modbusAddr, handler)
+	}()
+
+	broker := mochi.New(&mochi.Options{
+		InlineClient: true,
+		Logger:       slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+	err := broker.AddHook(new(auth.AllowHook), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener := listeners.NewTCP(listeners.Config{ID: "tcp", Address: "127.0.0.1:0"})
+	err = broker.AddListener(listener)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = broker.Serve()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = broker.Close()
+	}()
+
+	var mutex sync.Mutex
+	received := make(map[string][]byte)
+	err = broker.Subscribe("#", 1, func(cl *mochi.Client, sub packets.Subscription, pk packets.Packet) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		received[pk.TopicName] = pk.Payload
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bus := prostar_pwm.NewModbusBus(modbus.ClientConfiguration{
+		URL:     "tcp://" + modbusAddr,
+		Timeout: 1 * time.Second,
+	})
+	defer func() {
+		_ = bus.Close()
+	}()
+	dev := bus.Dev(1)
+
+	group, err := findPollGroup("charger-status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	publisher := &mqttPublisher{
+		topicPrefix:     "prostar-pwm/test",
+		nodeId:          "test",
+		discovery:       true,
+		discoveryPrefix: "homeassistant",
+		groups:          []pollGroup{group},
+	}
+	connected := make(chan struct{})
+	opts := mqtt.NewClientOptions().
+		AddBroker("tcp://" + listener.Address()).
+		SetClientID("test").
+		SetOnConnectHandler(func(client mqtt.Client) {
+			publisher.onConnect(client)
+			close(connected)
+		})
+	publisher.client = mqtt.NewClient(opts)
+	token := publisher.client.Connect()
+	if !token.WaitTimeout(5 * time.Second) {
+		t.Fatal("connect timed out")
+	}
+	if token.Error() != nil {
+		t.Fatal(token.Error())
+	}
+	defer publisher.client.Disconnect(250)
+	select {
+	case <-connected:
+	case <-time.After(5 * time.Second):
+		t.Fatal("on connect handler not called")
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		publisher.poll(ctx, dev)
+		mutex.Lock()
+		_, ok := received["prostar-pwm/test/charger-status"]
+		mutex.Unlock()
+		if ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("no charger-status reading published")
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	// a Home Assistant restart must bring the discovery configs back
+	configTopic := "homeassistant/sensor/test/battery_voltage/config"
+	mutex.Lock()
+	delete(received, configTopic)
+	mutex.Unlock()
+	err = broker.Publish("homeassistant/status", []byte(mqttPayloadOnline), false, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadline = time.Now().Add(5 * time.Second)
+	for {
+		mutex.Lock()
+		_, ok := received[configTopic]
+		mutex.Unlock()
+		if ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("discovery configs not re-sent after homeassistant/status online")
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if string(received["prostar-pwm/test/availability"]) != mqttPayloadOnline {
+		t.Errorf("availability = %q, want %q", received["prostar-pwm/test/availability"], mqttPayloadOnline)
+	}
+
+	var state map[string]any
+	err = json.Unmarshal(received["prostar-pwm/test/charger-status"], &state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := state["BatteryVoltage"].(float64); !ok {
+		t.Errorf("BatteryVoltage missing from state: %v", state)
+	}
+
+	var config map[string]any
+	err = json.Unmarshal(received[configTopic], &config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config["state_topic"] != "prostar-pwm/test/charger-status" {
+		t.Errorf("state_topic = %v", config["state_topic"])
+	}
+	if _, ok := received["homeassistant/sensor/test/array_voltage/config"]; ok {
+		t.Errorf("discovery published for a group that is not polled")
+	}
+}
+
+func freeAddr(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	_ = l.Close()
+	return addr
+}
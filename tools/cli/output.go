@@ -0,0 +1,324 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/urfave/cli/v3"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	outputFormatDump  = "dump"
+	outputFormatJSON  = "json"
+	outputFormatYAML  = "yaml"
+	outputFormatCSV   = "csv"
+	outputFormatTable = "table"
+)
+
+var (
+	outputFormats = []string{outputFormatDump, outputFormatJSON, outputFormatYAML, outputFormatCSV, outputFormatTable}
+)
+
+type flagger interface {
+	ActiveFlags() []string
+}
+
+func parseOutputFormat(s string) (string, error) {
+	s = strings.ToLower(s)
+	for _, format := range outputFormats {
+		if s == format {
+			return format, nil
+		}
+	}
+	return "", fmt.Errorf("invalid output format: %s", s)
+}
+
+func output(cmd *cli.Command, v any) error {
+	format, err := parseOutputFormat(cmd.String(outputFlag.Name))
+	if err != nil {
+		return err
+	}
+	return writeOutput(os.Stdout, format, v)
+}
+
+func writeOutput(w io.Writer, format string, v any) error {
+	switch format {
+	case outputFormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(normalize(reflect.ValueOf(v)))
+	case outputFormatYAML:
+		encoder := yaml.NewEncoder(w)
+		encoder.SetIndent(2)
+		err := encoder.Encode(normalize(reflect.ValueOf(v)))
+		if err != nil {
+			return err
+		}
+		return encoder.Close()
+	case outputFormatCSV:
+		return writeCSV(w, normalize(reflect.ValueOf(v)))
+	case outputFormatTable:
+		return writeTable(w, normalize(reflect.ValueOf(v)))
+	default:
+		return dump(v)
+	}
+}
+
+type orderedMapEntry struct {
+	Key   string
+	Value any
+}
+
+// orderedMap keeps struct field order when marshalled to JSON or YAML.
+type orderedMap []orderedMapEntry
+
+func (m orderedMap) MarshalJSON() ([]byte, error) {
+	var sb strings.Builder
+	sb.WriteString("{")
+	for i, entry := range m {
+		if i > 0 {
+			sb.WriteString(",")
+		}
+		key, err := json.Marshal(entry.Key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(entry.Value)
+		if err != nil {
+			return nil, err
+		}
+		sb.Write(key)
+		sb.WriteString(":")
+		sb.Write(value)
+	}
+	sb.WriteString("}")
+	return []byte(sb.String()), nil
+}
+
+func (m orderedMap) MarshalYAML() (any, error) {
+	node := &yaml.Node{
+		Kind: yaml.MappingNode,
+	}
+	for _, entry := range m {
+		var valueNode yaml.Node
+		err := valueNode.Encode(entry.Value)
+		if err != nil {
+			return nil, err
+		}
+		node.Content = append(node.Content, &yaml.Node{
+			Kind:  yaml.ScalarNode,
+			Value: entry.Key,
+		}, &valueNode)
+	}
+	return node, nil
+}
+
+// normalize converts v into plain values for the encoders: enums become their String() names,
+// fault and alarm bitfields become lists of active flags, and structs become ordered maps.
+func normalize(v reflect.Value) any {
+	if !v.IsValid() {
+		return nil
+	}
+	if (v.Kind() == reflect.Pointer) || (v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return nil
+		}
+		// errors such as *RegisterError implement error on the pointer
+		if v.CanInterface() {
+			if err, ok := v.Interface().(error); ok {
+				return err.Error()
+			}
+		}
+		return normalize(v.Elem())
+	}
+	if v.CanInterface() {
+		switch x := v.Interface().(type) {
+		case time.Time:
+			return x.Format(time.RFC3339Nano)
+		case time.Duration:
+			return x.String()
+		case flagger:
+			return x.ActiveFlags()
+		case error:
+			return x.Error()
+		case fmt.Stringer:
+			if v.Kind() != reflect.Struct {
+				return x.String()
+			}
+		}
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		var m orderedMap
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			m = append(m, orderedMapEntry{
+				Key:   field.Name,
+				Value: normalize(v.Field(i)),
+			})
+		}
+		return m
+	case reflect.Slice, reflect.Array:
+		values := make([]any, v.Len())
+		for i := range values {
+			values[i] = normalize(v.Index(i))
+		}
+		return values
+	case reflect.Map:
+		m := make(map[string]any, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			m[fmt.Sprint(iter.Key().Interface())] = normalize(iter.Value())
+		}
+		return m
+	default:
+		return v.Interface()
+	}
+}
+
+// flatten turns a normalized value into dotted column names and cell values.
+func flatten(prefix string, v any, columns *[]string, cells map[string]string) {
+	switch x := v.(type) {
+	case orderedMap:
+		for _, entry := range x {
+			key := entry.Key
+			if prefix != "" {
+				key = prefix + "." + entry.Key
+			}
+			flatten(key, entry.Value, columns, cells)
+		}
+		return
+	case map[string]any:
+		keys := make([]string, 0, len(x))
+		for key := range x {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			column := key
+			if prefix != "" {
+				column = prefix + "." + key
+			}
+			flatten(column, x[key], columns, cells)
+		}
+		return
+	}
+	if _, ok := cells[prefix]; !ok {
+		*columns = append(*columns, prefix)
+	}
+	cells[prefix] = formatCell(v)
+}
+
+func formatCell(v any) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return x
+	case []string:
+		return strings.Join(x, ", ")
+	case []any:
+		parts := make([]string, len(x))
+		for i, e := range x {
+			parts[i] = formatCell(e)
+		}
+		return strings.Join(parts, ", ")
+	case float32:
+		return strconv.FormatFloat(float64(x), 'f', -1, 32)
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	default:
+		return fmt.Sprint(x)
+	}
+}
+
+func rows(v any) ([]string, []map[string]string) {
+	var items []any
+	if list, ok := v.([]any); ok {
+		items = list
+	} else {
+		items = []any{v}
+	}
+	var columns []string
+	seen := make(map[string]bool)
+	var result []map[string]string
+	for _, item := range items {
+		var itemColumns []string
+		cells := make(map[string]string)
+		flatten("", item, &itemColumns, cells)
+		for _, column := range itemColumns {
+			if !seen[column] {
+				seen[column] = true
+				columns = append(columns, column)
+			}
+		}
+		result = append(result, cells)
+	}
+	return columns, result
+}
+
+func writeCSV(w io.Writer, v any) error {
+	columns, records := rows(v)
+	csvWriter := csv.NewWriter(w)
+	err := csvWriter.Write(columns)
+	if err != nil {
+		return err
+	}
+	for _, record := range records {
+		row := make([]string, len(columns))
+		for i, column := range columns {
+			row[i] = record[column]
+		}
+		err = csvWriter.Write(row)
+		if err != nil {
+			return err
+		}
+	}
+	csvWriter.Flush()
+	return csvWriter.Error()
+}
+
+func writeTable(w io.Writer, v any) error {
+	columns, records := rows(v)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	if _, ok := v.([]any); ok {
+		_, err := fmt.Fprintln(tw, strings.Join(columns, "\t"))
+		if err != nil {
+			return err
+		}
+		for _, record := range records {
+			row := make([]string, len(columns))
+			for i, column := range columns {
+				row[i] = record[column]
+			}
+			_, err = fmt.Fprintln(tw, strings.Join(row, "\t"))
+			if err != nil {
+				return err
+			}
+		}
+	} else {
+		for _, record := range records {
+			for _, column := range columns {
+				_, err := fmt.Fprintf(tw, "%s\t%s\n", column, record[column])
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return tw.Flush()
+}
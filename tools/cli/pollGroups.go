@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	prostar_pwm "github.com/ngyewch/prostar-pwm"
+)
+
+type pollGroup struct {
+	Name string
+	Key  string
+	Read func(ctx context.Context, dev *prostar_pwm.Dev) (any, error)
+}
+
+var (
+	pollGroups = []pollGroup{
+		{
+			Name: "raw-adc-data",
+			Key:  "RawADCData",
+			Read: func(ctx context.Context, dev *prostar_pwm.Dev) (any, error) {
+				return dev.ReadRawADCDataContext(ctx)
+			},
+		},
+		{
+			Name: "filtered-adc-data",
+			Key:  "FilteredADCData",
+			Read: func(ctx context.Context, dev *prostar_pwm.Dev) (any, error) {
+				return dev.ReadFilteredADCDataContext(ctx)
+			},
+		},
+		{
+			Name: "temperature-data",
+			Key:  "TemperatureData",
+			Read: func(ctx context.Context, dev *prostar_pwm.Dev) (any, error) {
+				return dev.ReadTemperatureDataContext(ctx)
+			},
+		},
+		{
+			Name: "charger-status",
+			Key:  "ChargerStatus",
+			Read: func(ctx context.Context, dev *prostar_pwm.Dev) (any, error) {
+				return dev.ReadChargerStatusContext(ctx)
+			},
+		},
+		{
+			Name: "load-status",
+			Key:  "LoadStatus",
+			Read: func(ctx context.Context, dev *prostar_pwm.Dev) (any, error) {
+				return dev.ReadLoadStatusContext(ctx)
+			},
+		},
+		{
+			Name: "misc-data",
+			Key:  "MiscData",
+			Read: func(ctx context.Context, dev *prostar_pwm.Dev) (any, error) {
+				return dev.ReadMiscDataContext(ctx)
+			},
+		},
+		{
+			Name: "statistics",
+			Key:  "Statistics",
+			Read: func(ctx context.Context, dev *prostar_pwm.Dev) (any, error) {
+				return dev.ReadStatisticsContext(ctx)
+			},
+		},
+	}
+)
+
+func findPollGroup(name string) (pollGroup, error) {
+	for _, group := range pollGroups {
+		if group.Name == name {
+			return group, nil
+		}
+	}
+	var names []string
+	for _, group := range pollGroups {
+		names = append(names, group.Name)
+	}
+	return pollGroup{}, fmt.Errorf("invalid group: %s (valid groups: %s)", name, strings.Join(names, ", "))
+}
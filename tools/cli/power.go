@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+
+	"github.com/urfave/cli/v3"
+)
+
+type powerResult struct {
+	ArrayPower              *float32
+	LoadPower               *float32
+	BatteryPower            *float32
+	ChargeEfficiency        *float32
+	BatteryTemperature      *float32
+	RegulationVoltageTarget *float32
+}
+
+func doPower(ctx context.Context, cmd *cli.Command) error {
+	dev, bus, err := newDev(cmd)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = bus.Close()
+	}()
+
+	filteredADCData, err := dev.ReadFilteredADCDataContext(ctx)
+	err = warnPartialRead(err)
+	if err != nil {
+		return err
+	}
+	temperatureData, err := dev.ReadTemperatureDataContext(ctx)
+	err = warnPartialRead(err)
+	if err != nil {
+		return err
+	}
+	chargeSettings, err := dev.ReadChargeSettingsContext(ctx)
+	err = warnPartialRead(err)
+	if err != nil {
+		return err
+	}
+
+	powerData := filteredADCData.PowerData()
+	result := powerResult{
+		ArrayPower:              powerData.ArrayPower,
+		LoadPower:               powerData.LoadPower,
+		BatteryPower:            powerData.BatteryPower,
+		ChargeEfficiency:        powerData.ChargeEfficiency,
+		BatteryTemperature:      temperatureData.Battery,
+		RegulationVoltageTarget: chargeSettings.RegulationVoltageTarget(temperatureData),
+	}
+
+	err = output(cmd, result)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
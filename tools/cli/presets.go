@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	prostar_pwm "github.com/ngyewch/prostar-pwm"
+	"github.com/urfave/cli/v3"
+)
+
+type presetsFile struct {
+	Presets []prostar_pwm.Preset
+}
+
+// loadPresets returns the custom presets from --presets-file, if any, followed by the built-in
+// presets, so that a custom preset overrides a built-in one of the same name.
+func loadPresets(cmd *cli.Command) ([]prostar_pwm.Preset, error) {
+	var presets []prostar_pwm.Preset
+	path := cmd.String(presetsFileFlag.Name)
+	if path != "" {
+		var f presetsFile
+		err := decodeFile(path, &f)
+		if err != nil {
+			return nil, err
+		}
+		for _, preset := range f.Presets {
+			if preset.Name == "" {
+				return nil, fmt.Errorf("%s: preset without name", path)
+			}
+			if (preset.SystemVoltage != 0) && (preset.SystemVoltage != 12) && (preset.SystemVoltage != 24) {
+				return nil, fmt.Errorf("%s: preset %s: invalid system voltage: %d", path, preset.Name, preset.SystemVoltage)
+			}
+		}
+		presets = append(presets, f.Presets...)
+	}
+	presets = append(presets, prostar_pwm.BuiltinPresets()...)
+	return presets, nil
+}
+
+func doSettingsPresets(ctx context.Context, cmd *cli.Command) error {
+	presets, err := loadPresets(cmd)
+	if err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, err = fmt.Fprintln(tw, "Name\tSystem voltage\tDescription")
+	if err != nil {
+		return err
+	}
+	seen := make(map[string]bool)
+	for _, preset := range presets {
+		if seen[preset.Name] {
+			continue
+		}
+		seen[preset.Name] = true
+		systemVoltage := "any"
+		if preset.SystemVoltage != 0 {
+			systemVoltage = fmt.Sprintf("%d V", preset.SystemVoltage)
+		}
+		_, err = fmt.Fprintf(tw, "%s\t%s\t%s\n", preset.Name, systemVoltage, preset.Description)
+		if err != nil {
+			return err
+		}
+	}
+	return tw.Flush()
+}
+
+func doSettingsApplyPreset(ctx context.Context, cmd *cli.Command) error {
+	if cmd.Args().Len() != 1 {
+		return fmt.Errorf("preset name not specified")
+	}
+	presets, err := loadPresets(cmd)
+	if err != nil {
+		return err
+	}
+	preset, ok := prostar_pwm.FindPreset(presets, cmd.Args().First())
+	if !ok {
+		return fmt.Errorf("unknown preset: %s", cmd.Args().First())
+	}
+
+	systemVoltage := int(cmd.Uint(settingsSystemVoltageFlag.Name))
+	if (preset.SystemVoltage != 0) && (preset.SystemVoltage != systemVoltage) {
+		return fmt.Errorf("preset %s is for a %d V system, not %d V", preset.Name, preset.SystemVoltage, systemVoltage)
+	}
+
+	dev, bus, err := newDev(cmd)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = bus.Close()
+	}()
+
+	return applySettings(ctx, cmd, dev, preset.Settings(), true)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	prostar_pwm "github.com/ngyewch/prostar-pwm"
+	"github.com/simonvetter/modbus"
+	"github.com/urfave/cli/v3"
+)
+
+func doScan(ctx context.Context, cmd *cli.Command) error {
+	first := cmd.Uint(scanFirstFlag.Name)
+	last := cmd.Uint(scanLastFlag.Name)
+	if (first < prostar_pwm.MinUnitId) || (last > prostar_pwm.MaxUnitId) || (first > last) {
+		return fmt.Errorf("invalid unit ID range %d-%d", first, last)
+	}
+	var unitIds []uint8
+	for unitId := first; unitId <= last; unitId++ {
+		unitIds = append(unitIds, uint8(unitId))
+	}
+
+	bus, err := newBus(cmd, func(cfg *modbus.ClientConfiguration) {
+		cfg.Timeout = cmd.Duration(scanTimeoutFlag.Name)
+	})
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = bus.Close()
+	}()
+
+	results, err := bus.Scan(ctx, unitIds, func(unitId uint8) {
+		_, _ = fmt.Fprintf(os.Stderr, "\rscanning unit ID %d/%d", unitId, last)
+	})
+	_, _ = fmt.Fprintf(os.Stderr, "\r\033[K%d controller(s) found\n", len(results))
+	if err != nil {
+		return err
+	}
+
+	return output(cmd, results)
+}
+
+func doSetModbusId(ctx context.Context, cmd *cli.Command) error {
+	if cmd.Args().Len() != 1 {
+		return fmt.Errorf("new unit ID not specified")
+	}
+	var unitId uint
+	_, err := fmt.Sscan(cmd.Args().First(), &unitId)
+	if err != nil || (unitId < prostar_pwm.MinUnitId) || (unitId > prostar_pwm.MaxUnitId) {
+		return fmt.Errorf("invalid unit ID: %s", cmd.Args().First())
+	}
+
+	dev, bus, err := newDev(cmd)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = bus.Close()
+	}()
+
+	movedDev, err := dev.SetModbusIdContext(ctx, uint8(unitId), cmd.Duration(setModbusIdWaitFlag.Name))
+	if err != nil {
+		return err
+	}
+
+	miscData, err := movedDev.ReadMiscDataContext(ctx)
+	if err != nil {
+		return err
+	}
+	hourmeter := "unknown"
+	if miscData.Hourmeter != nil {
+		hourmeter = fmt.Sprintf("%d h", *miscData.Hourmeter)
+	}
+	_, _ = fmt.Fprintf(os.Stderr, "unit ID changed from %d to %d, device answering (hourmeter %s)\n", cmd.Uint(modbusUnitIdFlag.Name), unitId, hourmeter)
+	return nil
+}
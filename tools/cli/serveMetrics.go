@@ -0,0 +1,389 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"reflect"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	prostar_pwm "github.com/ngyewch/prostar-pwm"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/urfave/cli/v3"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	metricsNamespace = "prostar_pwm"
+)
+
+func newMetricDesc(name string, help string, labels ...string) *prometheus.Desc {
+	return prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "", name), help, labels, nil)
+}
+
+var (
+	upDesc                 = newMetricDesc("up", "Whether the last poll of every group succeeded.")
+	lastPollDesc           = newMetricDesc("last_poll_timestamp_seconds", "Time of the last poll.")
+	pollDurationDesc       = newMetricDesc("poll_duration_seconds", "Duration of the last poll.")
+	pollErrorsDesc         = newMetricDesc("poll_errors_total", "Number of failed group reads.", "group")
+	groupUpDesc            = newMetricDesc("group_up", "Whether the last read of the group succeeded.", "group")
+	requestsDesc           = newMetricDesc("modbus_requests_total", "Number of Modbus requests (with --retries).")
+	retriesDesc            = newMetricDesc("modbus_retries_total", "Number of repeated Modbus request attempts (with --retries).")
+	recoveredDesc          = newMetricDesc("modbus_recovered_total", "Number of Modbus requests that succeeded after a retry (with --retries).")
+	failuresDesc           = newMetricDesc("modbus_failures_total", "Number of Modbus requests that failed after the last attempt (with --retries).")
+	arrayCurrentDesc       = newMetricDesc("array_current_amperes", "Array current.")
+	arrayPowerDesc         = newMetricDesc("array_power_watts", "Array power.")
+	loadPowerDesc          = newMetricDesc("load_power_watts", "Load power.")
+	batteryPowerDesc       = newMetricDesc("battery_power_watts", "Net battery power (positive while charging).")
+	chargeEfficiencyDesc   = newMetricDesc("charge_efficiency_ratio", "Estimated share of the array power delivered to the battery and load.")
+	batteryTerminalDesc    = newMetricDesc("battery_terminal_voltage_volts", "Battery terminal voltage.")
+	arrayVoltageDesc       = newMetricDesc("array_voltage_volts", "Array voltage.")
+	loadVoltageDesc        = newMetricDesc("load_voltage_volts", "Load voltage.")
+	loadCurrentDesc        = newMetricDesc("load_current_amperes", "Load current.")
+	batterySenseDesc       = newMetricDesc("battery_sense_voltage_volts", "Battery sense voltage.")
+	batteryVoltageSlowDesc = newMetricDesc("battery_voltage_slow_volts", "Battery voltage, slow filter (60s).")
+	batteryCurrentDesc     = newMetricDesc("battery_current_amperes", "Net battery current, slow filter (60s).")
+	temperatureDesc        = newMetricDesc("temperature_celsius", "Temperature.", "sensor")
+	chargeStateDesc        = newMetricDesc("charge_state", "Charge state.", "state")
+	arrayFaultDesc         = newMetricDesc("array_fault", "Array fault flag.", "flag")
+	batteryVoltageDesc     = newMetricDesc("battery_voltage_volts", "Battery voltage, slow filter (25s).")
+	batteryRefDesc         = newMetricDesc("battery_regulator_reference_voltage_volts", "Battery regulator reference voltage.")
+	ahChargeResetDesc      = newMetricDesc("charge_resettable_ampere_hours", "Ah charge, resettable.")
+	ahChargeTotalDesc      = newMetricDesc("charge_ampere_hours_total", "Ah charge, total.")
+	kWhChargeResetDesc     = newMetricDesc("charge_resettable_kilowatt_hours", "kWh charge, resettable.")
+	kWhChargeTotalDesc     = newMetricDesc("charge_kilowatt_hours_total", "kWh charge, total.")
+	foldback100Desc        = newMetricDesc("battery_temperature_foldback_100_percent_limit_celsius", "Battery temperature foldback 100% output limit.")
+	foldback0Desc          = newMetricDesc("battery_temperature_foldback_0_percent_limit_celsius", "Battery temperature foldback 0% output limit.")
+	loadStateDesc          = newMetricDesc("load_state", "Load state.", "state")
+	loadFaultDesc          = newMetricDesc("load_fault", "Load fault flag.", "flag")
+	loadLVDDesc            = newMetricDesc("load_lvd_voltage_volts", "Load current compensated LVD voltage.")
+	loadHVDDesc            = newMetricDesc("load_hvd_voltage_volts", "Load HVD voltage.")
+	ahLoadResetDesc        = newMetricDesc("load_resettable_ampere_hours", "Ah load, resettable.")
+	ahLoadTotalDesc        = newMetricDesc("load_ampere_hours_total", "Ah load, total.")
+	alarmDesc              = newMetricDesc("alarm", "Alarm flag.", "flag")
+	hourmeterDesc          = newMetricDesc("hourmeter_hours", "Hourmeter.")
+	statAhLoadResetDesc    = newMetricDesc("statistics_load_resettable_ampere_hours", "Ah load, resettable (EEPROM).")
+	statAhLoadTotalDesc    = newMetricDesc("statistics_load_ampere_hours_total", "Ah load, total (EEPROM).")
+	statAhChargeResetDesc  = newMetricDesc("statistics_charge_resettable_ampere_hours", "Ah charge, resettable (EEPROM).")
+	statAhChargeTotalDesc  = newMetricDesc("statistics_charge_ampere_hours_total", "Ah charge, total (EEPROM).")
+	statKWhResetDesc       = newMetricDesc("statistics_charge_resettable_kilowatt_hours", "kWh charge, resettable (EEPROM).")
+	statKWhTotalDesc       = newMetricDesc("statistics_charge_kilowatt_hours_total", "kWh charge, total (EEPROM).")
+	statBattMinDesc        = newMetricDesc("statistics_battery_voltage_minimum_volts", "Battery voltage minimum (EEPROM).")
+	statBattMaxDesc        = newMetricDesc("statistics_battery_voltage_maximum_volts", "Battery voltage maximum (EEPROM).")
+	statArrayMaxDesc       = newMetricDesc("statistics_array_voltage_maximum_volts", "Array voltage maximum (EEPROM).")
+	statLastEqualizeDesc   = newMetricDesc("statistics_time_since_last_equalize_days", "Days since the last equalize.")
+)
+
+var (
+	metricsGroups = []string{"filtered-adc-data", "temperature-data", "charger-status", "load-status", "misc-data", "statistics"}
+)
+
+type metricsSnapshot struct {
+	Time            time.Time
+	Duration        time.Duration
+	FilteredADCData *prostar_pwm.FilteredADCData
+	TemperatureData *prostar_pwm.TemperatureData
+	ChargerStatus   *prostar_pwm.ChargerStatus
+	LoadStatus      *prostar_pwm.LoadStatus
+	MiscData        *prostar_pwm.MiscData
+	Statistics      *prostar_pwm.Statistics
+	Errors          map[string]error
+}
+
+// metricsCollector serves scrapes from the snapshot taken by the last poll, so that scrapes never
+// touch the bus.
+type metricsCollector struct {
+	dev        *prostar_pwm.Dev
+	mutex      sync.RWMutex
+	snapshot   *metricsSnapshot
+	pollErrors map[string]uint64
+}
+
+func newMetricsCollector(dev *prostar_pwm.Dev) *metricsCollector {
+	return &metricsCollector{
+		dev:        dev,
+		pollErrors: make(map[string]uint64),
+	}
+}
+
+func (c *metricsCollector) poll(ctx context.Context) {
+	snapshot := &metricsSnapshot{
+		Time:   time.Now(),
+		Errors: make(map[string]error),
+	}
+	var err error
+	snapshot.FilteredADCData, err = readPtr(ctx, c.dev.ReadFilteredADCDataContext)
+	if err != nil {
+		snapshot.Errors["filtered-adc-data"] = err
+	}
+	snapshot.TemperatureData, err = readPtr(ctx, c.dev.ReadTemperatureDataContext)
+	if err != nil {
+		snapshot.Errors["temperature-data"] = err
+	}
+	snapshot.ChargerStatus, err = readPtr(ctx, c.dev.ReadChargerStatusContext)
+	if err != nil {
+		snapshot.Errors["charger-status"] = err
+	}
+	snapshot.LoadStatus, err = readPtr(ctx, c.dev.ReadLoadStatusContext)
+	if err != nil {
+		snapshot.Errors["load-status"] = err
+	}
+	snapshot.MiscData, err = readPtr(ctx, c.dev.ReadMiscDataContext)
+	if err != nil {
+		snapshot.Errors["misc-data"] = err
+	}
+	snapshot.Statistics, err = readPtr(ctx, c.dev.ReadStatisticsContext)
+	if err != nil {
+		snapshot.Errors["statistics"] = err
+	}
+	snapshot.Duration = time.Since(snapshot.Time)
+	if ctx.Err() != nil {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for group, err := range snapshot.Errors {
+		log.Printf("%s: %v", group, err)
+		c.pollErrors[group]++
+	}
+	c.snapshot = snapshot
+}
+
+func readPtr[T any](ctx context.Context, read func(ctx context.Context) (T, error)) (*T, error) {
+	v, err := read(ctx)
+	if (err != nil) && !errors.Is(err, prostar_pwm.ErrPartialRead) {
+		return nil, err
+	}
+	return &v, err
+}
+
+func (c *metricsCollector) Run(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		c.poll(ctx)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Describe sends no descriptors, making this an unchecked collector: the set of metrics depends on
+// which groups the last poll managed to read.
+func (c *metricsCollector) Describe(ch chan<- *prometheus.Desc) {
+}
+
+func (c *metricsCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	for _, group := range metricsGroups {
+		ch <- prometheus.MustNewConstMetric(pollErrorsDesc, prometheus.CounterValue, float64(c.pollErrors[group]), group)
+	}
+
+	retryStats := c.dev.RetryStats()
+	ch <- prometheus.MustNewConstMetric(requestsDesc, prometheus.CounterValue, float64(retryStats.Requests))
+	ch <- prometheus.MustNewConstMetric(retriesDesc, prometheus.CounterValue, float64(retryStats.Retries))
+	ch <- prometheus.MustNewConstMetric(recoveredDesc, prometheus.CounterValue, float64(retryStats.Recovered))
+	ch <- prometheus.MustNewConstMetric(failuresDesc, prometheus.CounterValue, float64(retryStats.Failures))
+
+	s := c.snapshot
+	if s == nil {
+		ch <- prometheus.MustNewConstMetric(upDesc, prometheus.GaugeValue, 0)
+		return
+	}
+
+	ch <- prometheus.MustNewConstMetric(upDesc, prometheus.GaugeValue, boolToFloat(len(s.Errors) == 0))
+	ch <- prometheus.MustNewConstMetric(lastPollDesc, prometheus.GaugeValue, float64(s.Time.UnixNano())/1e9)
+	ch <- prometheus.MustNewConstMetric(pollDurationDesc, prometheus.GaugeValue, s.Duration.Seconds())
+	for _, group := range metricsGroups {
+		ch <- prometheus.MustNewConstMetric(groupUpDesc, prometheus.GaugeValue, boolToFloat(s.Errors[group] == nil), group)
+	}
+
+	if d := s.FilteredADCData; d != nil {
+		gauge(ch, arrayCurrentDesc, d.ArrayCurrent)
+		gauge(ch, batteryTerminalDesc, d.BatteryTerminalVoltage)
+		gauge(ch, arrayVoltageDesc, d.ArrayVoltage)
+		gauge(ch, loadVoltageDesc, d.LoadVoltage)
+		gauge(ch, loadCurrentDesc, d.LoadCurrent)
+		gauge(ch, batterySenseDesc, d.BatterySenseVoltage)
+		gauge(ch, batteryVoltageSlowDesc, d.BatteryVoltage)
+		gauge(ch, batteryCurrentDesc, d.BatteryCurrent)
+		gauge(ch, arrayPowerDesc, d.ArrayPower())
+		gauge(ch, loadPowerDesc, d.LoadPower())
+		gauge(ch, batteryPowerDesc, d.BatteryPower())
+		gauge(ch, chargeEfficiencyDesc, d.ChargeEfficiency())
+	}
+
+	if d := s.TemperatureData; d != nil {
+		gauge(ch, temperatureDesc, d.Heatsink, "heatsink")
+		gauge(ch, temperatureDesc, d.Battery, "battery")
+		gauge(ch, temperatureDesc, d.Ambient, "ambient")
+		gauge(ch, temperatureDesc, d.Remote, "remote")
+	}
+
+	if d := s.ChargerStatus; d != nil {
+		if d.ChargeState != nil {
+			for state := prostar_pwm.ChargeStateStart; state <= prostar_pwm.ChargeStateEqualize; state++ {
+				ch <- prometheus.MustNewConstMetric(chargeStateDesc, prometheus.GaugeValue, boolToFloat(state == *d.ChargeState), state.String())
+			}
+			if *d.ChargeState > prostar_pwm.ChargeStateEqualize {
+				ch <- prometheus.MustNewConstMetric(chargeStateDesc, prometheus.GaugeValue, 1, d.ChargeState.String())
+			}
+		}
+		if d.ArrayFault != nil {
+			flagGauges(ch, arrayFaultDesc, *d.ArrayFault)
+		}
+		gauge(ch, batteryVoltageDesc, d.BatteryVoltage)
+		gauge(ch, batteryRefDesc, d.BatteryRegulatorReferenceVoltage)
+		gauge(ch, ahChargeResetDesc, d.AhChargeResettable)
+		counter(ch, ahChargeTotalDesc, d.AhChargeTotal)
+		gauge(ch, kWhChargeResetDesc, d.KWhChargeResettable)
+		counter(ch, kWhChargeTotalDesc, d.KWhChargeTotal)
+		gauge(ch, foldback100Desc, d.BatteryTemperatureFoldback100PercentOutputLimit)
+		gauge(ch, foldback0Desc, d.BatteryTemperatureFoldback0PercentOutputLimit)
+	}
+
+	if d := s.LoadStatus; d != nil {
+		if d.LoadState != nil {
+			for state := prostar_pwm.LoadStateStart; state <= prostar_pwm.LoadStateOverride; state++ {
+				ch <- prometheus.MustNewConstMetric(loadStateDesc, prometheus.GaugeValue, boolToFloat(state == *d.LoadState), state.String())
+			}
+			if *d.LoadState > prostar_pwm.LoadStateOverride {
+				ch <- prometheus.MustNewConstMetric(loadStateDesc, prometheus.GaugeValue, 1, d.LoadState.String())
+			}
+		}
+		if d.LoadFault != nil {
+			flagGauges(ch, loadFaultDesc, *d.LoadFault)
+		}
+		gauge(ch, loadLVDDesc, d.LoadCurrentCompensatedLVDVoltage)
+		gauge(ch, loadHVDDesc, d.LoadHVDVoltage)
+		gauge(ch, ahLoadResetDesc, d.AhLoadResettable)
+		counter(ch, ahLoadTotalDesc, d.AhLoadTotal)
+	}
+
+	if d := s.MiscData; d != nil {
+		if d.Alarm != nil {
+			flagGauges(ch, alarmDesc, *d.Alarm)
+		}
+	}
+
+	if d := s.Statistics; d != nil {
+		if d.Hourmeter != nil {
+			ch <- prometheus.MustNewConstMetric(hourmeterDesc, prometheus.GaugeValue, float64(*d.Hourmeter))
+		}
+		gauge(ch, statAhLoadResetDesc, d.AhLoadResettable)
+		counter(ch, statAhLoadTotalDesc, d.AhLoadTotal)
+		gauge(ch, statAhChargeResetDesc, d.AhChargeResettable)
+		counter(ch, statAhChargeTotalDesc, d.AhChargeTotal)
+		gauge(ch, statKWhResetDesc, d.KWhcResettable)
+		counter(ch, statKWhTotalDesc, d.KWhcTotal)
+		gauge(ch, statBattMinDesc, d.BatteryVoltageMinimum)
+		gauge(ch, statBattMaxDesc, d.BatteryVoltageMaximum)
+		gauge(ch, statArrayMaxDesc, d.ArrayVoltageMaximum)
+		if d.TimeSinceLastEqualize != nil {
+			ch <- prometheus.MustNewConstMetric(statLastEqualizeDesc, prometheus.GaugeValue, float64(*d.TimeSinceLastEqualize))
+		}
+	}
+}
+
+func gauge(ch chan<- prometheus.Metric, desc *prometheus.Desc, v *float32, labelValues ...string) {
+	if v == nil {
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, toFloat64(*v), labelValues...)
+}
+
+func counter(ch chan<- prometheus.Metric, desc *prometheus.Desc, v *float32) {
+	if v == nil {
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, toFloat64(*v))
+}
+
+// flagGauges emits one gauge per bool field of a fault or alarm details struct.
+func flagGauges(ch chan<- prometheus.Metric, desc *prometheus.Desc, details any) {
+	v := reflect.ValueOf(details)
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).Type.Kind() != reflect.Bool {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, boolToFloat(v.Field(i).Bool()), t.Field(i).Name)
+	}
+}
+
+// toFloat64 converts via the shortest decimal representation, so that 14599.2 is not exported as
+// 14599.2001953125.
+func toFloat64(v float32) float64 {
+	f, _ := strconv.ParseFloat(strconv.FormatFloat(float64(v), 'f', -1, 32), 64)
+	return f
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func doServeMetrics(ctx context.Context, cmd *cli.Command) error {
+	ctx, cancel := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	dev, bus, err := newDev(cmd)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = bus.Close()
+	}()
+
+	collector := newMetricsCollector(dev)
+	registry := prometheus.NewRegistry()
+	err = registry.Register(collector)
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{
+		EnableOpenMetrics: true,
+	}))
+	server := &http.Server{
+		Addr:    cmd.String(serveMetricsListenFlag.Name),
+		Handler: mux,
+	}
+
+	g, ctx := errgroup.WithContext(ctx)
+	g.Go(func() error {
+		return collector.Run(ctx, cmd.Duration(serveMetricsIntervalFlag.Name))
+	})
+	g.Go(func() error {
+		log.Printf("serving metrics on http://%s/metrics", server.Addr)
+		err := server.ListenAndServe()
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return err
+	})
+	g.Go(func() error {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return server.Shutdown(shutdownCtx)
+	})
+
+	return g.Wait()
+}
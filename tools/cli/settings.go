@@ -0,0 +1,270 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	prostar_pwm "github.com/ngyewch/prostar-pwm"
+	"github.com/urfave/cli/v3"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	settingsFileVersion = 1
+)
+
+type settingsFile struct {
+	Version        int
+	ExportedAt     time.Time
+	UnitId         uint8
+	Hourmeter      *uint32
+	ChargeSettings prostar_pwm.ChargeSettings
+	LoadSettings   prostar_pwm.LoadSettings
+	MiscSettings   prostar_pwm.MiscSettings
+	PWMSettings    prostar_pwm.PWMSettings
+}
+
+func (f settingsFile) Settings() prostar_pwm.Settings {
+	return prostar_pwm.Settings{
+		ChargeSettings: f.ChargeSettings,
+		LoadSettings:   f.LoadSettings,
+		MiscSettings:   f.MiscSettings,
+		PWMSettings:    f.PWMSettings,
+	}
+}
+
+func settingsFileFormat(path string, format string) (string, error) {
+	if format == "" {
+		switch {
+		case strings.HasSuffix(path, ".json"):
+			format = outputFormatJSON
+		default:
+			format = outputFormatYAML
+		}
+	}
+	switch format {
+	case outputFormatJSON, outputFormatYAML:
+		return format, nil
+	}
+	return "", fmt.Errorf("invalid format: %s", format)
+}
+
+func writeSettingsFile(w io.Writer, format string, f settingsFile) error {
+	if format == outputFormatJSON {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(f)
+	}
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	err := encoder.Encode(normalize(reflect.ValueOf(f)))
+	if err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+// decodeFile decodes a YAML or JSON file (JSON being valid YAML as well) into v. The document is
+// decoded generically and then converted via JSON, so that keys match the Go field names as in the
+// export.
+func decodeFile(path string, v any) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var doc any
+	err = yaml.Unmarshal(b, &doc)
+	if err != nil {
+		return err
+	}
+	jsonBytes, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(jsonBytes))
+	decoder.DisallowUnknownFields()
+	err = decoder.Decode(v)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+func readSettingsFile(path string) (settingsFile, error) {
+	var f settingsFile
+	err := decodeFile(path, &f)
+	if err != nil {
+		return settingsFile{}, err
+	}
+	if f.Version != settingsFileVersion {
+		return settingsFile{}, fmt.Errorf("%s: unsupported settings file version %d", path, f.Version)
+	}
+	return f, nil
+}
+
+func doSettingsExport(ctx context.Context, cmd *cli.Command) error {
+	path := cmd.Args().First()
+	format, err := settingsFileFormat(path, cmd.String(settingsFormatFlag.Name))
+	if err != nil {
+		return err
+	}
+
+	dev, bus, err := newDev(cmd)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = bus.Close()
+	}()
+
+	settings, err := dev.ReadSettingsContext(ctx)
+	if err != nil {
+		return err
+	}
+	statistics, err := dev.ReadStatisticsContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	f := settingsFile{
+		Version:        settingsFileVersion,
+		ExportedAt:     time.Now().UTC(),
+		UnitId:         uint8(cmd.Uint(modbusUnitIdFlag.Name)),
+		Hourmeter:      statistics.Hourmeter,
+		ChargeSettings: settings.ChargeSettings,
+		LoadSettings:   settings.LoadSettings,
+		MiscSettings:   settings.MiscSettings,
+		PWMSettings:    settings.PWMSettings,
+	}
+
+	if (path == "") || (path == "-") {
+		return writeSettingsFile(os.Stdout, format, f)
+	}
+	w, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = writeSettingsFile(w, format, f)
+	if err != nil {
+		_ = w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+func doSettingsImport(ctx context.Context, cmd *cli.Command) error {
+	if cmd.Args().Len() != 1 {
+		return fmt.Errorf("settings file not specified")
+	}
+	f, err := readSettingsFile(cmd.Args().First())
+	if err != nil {
+		return err
+	}
+	proposed := f.Settings()
+	if !cmd.Bool(settingsIncludeModbusIdFlag.Name) {
+		// importing another site's file must not move this device to a different unit ID
+		proposed.MiscSettings.ModbusID = nil
+	}
+
+	hourmeter := "unknown"
+	if f.Hourmeter != nil {
+		hourmeter = fmt.Sprintf("%d h", *f.Hourmeter)
+	}
+	_, _ = fmt.Fprintf(os.Stderr, "settings exported %s from unit ID %d (hourmeter %s)\n", f.ExportedAt.Format(time.RFC3339), f.UnitId, hourmeter)
+
+	dev, bus, err := newDev(cmd)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = bus.Close()
+	}()
+
+	return applySettings(ctx, cmd, dev, proposed, false)
+}
+
+// applySettings shows the changes from the device settings to proposed, validates them and, unless
+// --dry-run is set, writes the changed registers and verifies them. If confirm is set, the user is
+// asked before writing unless --yes is set.
+func applySettings(ctx context.Context, cmd *cli.Command, dev *prostar_pwm.Dev, proposed prostar_pwm.Settings, confirm bool) error {
+	current, err := dev.ReadSettingsContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	warnDIPSwitch(ctx, dev)
+
+	changes := prostar_pwm.DiffSettings(current, proposed)
+	if len(changes) == 0 {
+		_, _ = fmt.Fprintln(os.Stderr, "no changes")
+		return nil
+	}
+	err = writeSettingsChanges(os.Stdout, changes)
+	if err != nil {
+		return err
+	}
+
+	changed := prostar_pwm.ChangedSettings(current, proposed)
+	maxChargeCurrent, err := modelMaxChargeCurrent(cmd.String(settingsModelFlag.Name))
+	if err != nil {
+		return err
+	}
+	err = prostar_pwm.ValidateSettings(changed, current, int(cmd.Uint(settingsSystemVoltageFlag.Name)), maxChargeCurrent)
+	if err != nil {
+		return err
+	}
+
+	if cmd.Bool(settingsDryRunFlag.Name) {
+		_, _ = fmt.Fprintf(os.Stderr, "dry run: %d change(s) not written\n", len(changes))
+		return nil
+	}
+	if confirm && !cmd.Bool(settingsYesFlag.Name) {
+		_, _ = fmt.Fprintf(os.Stderr, "write %d change(s)? [y/N] ", len(changes))
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		answer = strings.ToLower(strings.TrimSpace(answer))
+		if (answer != "y") && (answer != "yes") {
+			return fmt.Errorf("aborted")
+		}
+	}
+
+	err = dev.WriteSettingsContext(ctx, changed)
+	if err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintf(os.Stderr, "%d change(s) written and verified\n", len(changes))
+	return nil
+}
+
+func modelMaxChargeCurrent(model string) (float32, error) {
+	switch strings.ToLower(model) {
+	case "ps-15", "ps-15m":
+		return prostar_pwm.MaxChargeCurrentPS15, nil
+	case "ps-30", "ps-30m":
+		return prostar_pwm.MaxChargeCurrentPS30, nil
+	}
+	return 0, fmt.Errorf("unsupported model: %s", model)
+}
+
+func writeSettingsChanges(w io.Writer, changes []prostar_pwm.SettingsChange) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, err := fmt.Fprintln(tw, "Field\tCurrent\tNew")
+	if err != nil {
+		return err
+	}
+	for _, change := range changes {
+		_, err = fmt.Fprintf(tw, "%s\t%s\t%s\n", change.Name(), formatCell(change.Current), formatCell(change.Proposed))
+		if err != nil {
+			return err
+		}
+	}
+	return tw.Flush()
+}
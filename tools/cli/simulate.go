@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/ngyewch/prostar-pwm/simulator"
+	"github.com/urfave/cli/v3"
+	"golang.org/x/sync/errgroup"
+)
+
+func doSimulate(ctx context.Context, cmd *cli.Command) error {
+	ctx, cancel := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	var simulators []*simulator.Simulator
+	for i, unitId := range cmd.UintSlice(simulateUnitIdFlag.Name) {
+		if (unitId < 1) || (unitId > 247) {
+			return fmt.Errorf("invalid unit-id: %d", unitId)
+		}
+		simulators = append(simulators, simulator.New(simulator.Config{
+			UnitId:        uint8(unitId),
+			SystemVoltage: int(cmd.Uint(simulateSystemVoltageFlag.Name)),
+			TimeScale:     cmd.Float(simulateTimeScaleFlag.Name),
+			Seed:          int64(i),
+		}))
+	}
+	handler := simulator.NewHandler(simulators...)
+
+	g, ctx := errgroup.WithContext(ctx)
+	for _, s := range simulators {
+		g.Go(func() error {
+			err := s.Run(ctx)
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		})
+	}
+	for _, listenURL := range cmd.StringSlice(simulateListenFlag.Name) {
+		g.Go(func() error {
+			return simulator.Serve(ctx, listenURL, handler)
+		})
+		log.Printf("serving %s", listenURL)
+	}
+	if cmd.Bool(simulatePTYFlag.Name) {
+		p, err := simulator.OpenPTY()
+		if err != nil {
+			return err
+		}
+		g.Go(func() error {
+			return p.Serve(ctx, handler)
+		})
+		log.Printf("serving Modbus RTU on %s", p.Name())
+	}
+
+	return g.Wait()
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+
+	prostar_pwm "github.com/ngyewch/prostar-pwm"
+	"github.com/urfave/cli/v3"
+)
+
+func doSnapshot(ctx context.Context, cmd *cli.Command) error {
+	dev, bus, err := newDev(cmd)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = bus.Close()
+	}()
+
+	result, err := dev.ReadSnapshotContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = output(cmd, result)
+	if err != nil {
+		return err
+	}
+
+	var groups []string
+	for group := range result.Errors {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+	for _, group := range groups {
+		err := result.Errors[group]
+		var registerError *prostar_pwm.RegisterError
+		if errors.As(err, &registerError) || errors.Is(err, prostar_pwm.ErrPartialRead) {
+			// already names the group
+			_, _ = fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+		} else {
+			_, _ = fmt.Fprintf(os.Stderr, "warning: %s: %v\n", group, err)
+		}
+	}
+	if (result.RawADCData == nil) && (result.FilteredADCData == nil) && (result.TemperatureData == nil) &&
+		(result.ChargerStatus == nil) && (result.LoadStatus == nil) && (result.MiscData == nil) {
+		return fmt.Errorf("no group could be read")
+	}
+
+	return nil
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"reflect"
+	"syscall"
+	"time"
+
+	prostar_pwm "github.com/ngyewch/prostar-pwm"
+	"github.com/urfave/cli/v3"
+)
+
+func doWatch(ctx context.Context, cmd *cli.Command) error {
+	ctx, cancel := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	var groups []pollGroup
+	for _, name := range cmd.StringSlice(watchGroupFlag.Name) {
+		group, err := findPollGroup(name)
+		if err != nil {
+			return err
+		}
+		groups = append(groups, group)
+	}
+
+	format, err := parseOutputFormat(cmd.String(outputFlag.Name))
+	if err != nil {
+		return err
+	}
+
+	dev, bus, err := newDev(cmd)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = bus.Close()
+	}()
+
+	interval := cmd.Duration(watchIntervalFlag.Name)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		sample := pollWatchGroups(ctx, dev, groups)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if format == outputFormatJSON {
+			err = json.NewEncoder(os.Stdout).Encode(sample)
+		} else {
+			err = redrawWatch(sample)
+		}
+		if err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// pollWatchGroups reads each group once. Read errors (typically timeouts) are recorded in the
+// sample rather than returned, so that a single missed poll does not stop the watch.
+func pollWatchGroups(ctx context.Context, dev *prostar_pwm.Dev, groups []pollGroup) orderedMap {
+	sample := orderedMap{
+		{Key: "Time", Value: time.Now().Format(time.RFC3339Nano)},
+	}
+	var errs orderedMap
+	for _, group := range groups {
+		result, err := group.Read(ctx, dev)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("%s: %v", group.Name, err)
+			}
+			errs = append(errs, orderedMapEntry{Key: group.Key, Value: err.Error()})
+			if !errors.Is(err, prostar_pwm.ErrPartialRead) {
+				sample = append(sample, orderedMapEntry{Key: group.Key, Value: nil})
+				continue
+			}
+		}
+		sample = append(sample, orderedMapEntry{Key: group.Key, Value: normalize(reflect.ValueOf(result))})
+	}
+	if len(errs) > 0 {
+		sample = append(sample, orderedMapEntry{Key: "Errors", Value: errs})
+	}
+	return sample
+}
+
+func redrawWatch(sample orderedMap) error {
+	var buf bytes.Buffer
+	buf.WriteString("\033[H\033[2J")
+	for _, entry := range sample {
+		switch entry.Key {
+		case "Time":
+			_, _ = fmt.Fprintf(&buf, "%s\n", entry.Value)
+		case "Errors":
+			_, _ = fmt.Fprintln(&buf)
+			for _, e := range entry.Value.(orderedMap) {
+				_, _ = fmt.Fprintf(&buf, "%s: %s\n", e.Key, e.Value)
+			}
+		default:
+			_, _ = fmt.Fprintf(&buf, "\n[%s]\n", entry.Key)
+			if entry.Value == nil {
+				continue
+			}
+			err := writeTable(&buf, entry.Value)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	_, err := os.Stdout.Write(buf.Bytes())
+	return err
+}
@@ -0,0 +1,117 @@
+package prostar_pwm
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/simonvetter/modbus"
+)
+
+var (
+	// ErrIllegalDataAddress is returned by a Transport when the device rejects a register or coil address.
+	ErrIllegalDataAddress = modbus.ErrIllegalDataAddress
+)
+
+type RegisterType int
+
+const (
+	InputRegister RegisterType = iota
+	HoldingRegister
+	// CoilRegister is only used to describe coil accesses in a RegisterError.
+	CoilRegister
+)
+
+func (v RegisterType) String() string {
+	switch v {
+	case InputRegister:
+		return "input register"
+	case HoldingRegister:
+		return "holding register"
+	case CoilRegister:
+		return "coil"
+	default:
+		return fmt.Sprintf("register type %d", int(v))
+	}
+}
+
+// Transport is the set of Modbus operations needed by Dev. Register values are raw 16-bit words in
+// big-endian byte order.
+type Transport interface {
+	SetUnitId(unitId uint8) error
+	ReadRegister(addr uint16, regType RegisterType) (uint16, error)
+	ReadRegisters(addr uint16, quantity uint16, regType RegisterType) ([]uint16, error)
+	WriteRegister(addr uint16, value uint16) error
+	ReadCoil(addr uint16) (bool, error)
+	WriteCoil(addr uint16, value bool) error
+}
+
+// ContextTransport is implemented by a Transport that uses the context of a request, for example to
+// end a wait when the request is cancelled. WithContext returns the Transport to use for a single
+// request with ctx. A Transport that wraps another should implement it and pass ctx on.
+type ContextTransport interface {
+	WithContext(ctx context.Context) Transport
+}
+
+// withContext returns transport bound to ctx if it is a ContextTransport, or transport otherwise.
+func withContext(transport Transport, ctx context.Context) Transport {
+	if contextTransport, ok := transport.(ContextTransport); ok {
+		return contextTransport.WithContext(ctx)
+	}
+	return transport
+}
+
+type ModbusClientTransport struct {
+	mc     *modbus.ModbusClient
+	config *modbus.ClientConfiguration // set if the client can be reopened for ExecutePDU
+	unitId uint8
+}
+
+func NewModbusClientTransport(mc *modbus.ModbusClient) *ModbusClientTransport {
+	return &ModbusClientTransport{
+		mc: mc,
+	}
+}
+
+func (t *ModbusClientTransport) SetUnitId(unitId uint8) error {
+	err := t.mc.SetUnitId(unitId)
+	if err != nil {
+		return err
+	}
+	t.unitId = unitId
+	err = t.mc.SetEncoding(modbus.BIG_ENDIAN, modbus.LOW_WORD_FIRST)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *ModbusClientTransport) ReadRegister(addr uint16, regType RegisterType) (uint16, error) {
+	return t.mc.ReadRegister(addr, toModbusRegType(regType))
+}
+
+func (t *ModbusClientTransport) ReadRegisters(addr uint16, quantity uint16, regType RegisterType) ([]uint16, error) {
+	return t.mc.ReadRegisters(addr, quantity, toModbusRegType(regType))
+}
+
+func (t *ModbusClientTransport) WriteRegister(addr uint16, value uint16) error {
+	return t.mc.WriteRegister(addr, value)
+}
+
+func (t *ModbusClientTransport) ReadCoil(addr uint16) (bool, error) {
+	return t.mc.ReadCoil(addr)
+}
+
+func (t *ModbusClientTransport) WriteCoil(addr uint16, value bool) error {
+	return t.mc.WriteCoil(addr, value)
+}
+
+func (t *ModbusClientTransport) Close() error {
+	return t.mc.Close()
+}
+
+func toModbusRegType(regType RegisterType) modbus.RegType {
+	if regType == InputRegister {
+		return modbus.INPUT_REGISTER
+	}
+	return modbus.HOLDING_REGISTER
+}
@@ -0,0 +1,92 @@
+package prostar_pwm
+
+// fakeTransport is an in-memory Transport. Every request fails with err if it is set, and register
+// reads covering an address in failing fail with its error. Raw PDUs are passed to pdu, and are not
+// supported if it is nil.
+type fakeTransport struct {
+	registers  map[uint16]uint16
+	coils      map[uint16]bool
+	failing    map[uint16]error
+	err        error
+	requests   int
+	writes     int
+	coilWrites int
+	pdu        func(functionCode uint8, data []byte) ([]byte, error)
+}
+
+func newFakeTransport() *fakeTransport {
+	return &fakeTransport{
+		registers: make(map[uint16]uint16),
+		coils:     make(map[uint16]bool),
+		failing:   make(map[uint16]error),
+	}
+}
+
+func (t *fakeTransport) SetUnitId(unitId uint8) error {
+	return nil
+}
+
+func (t *fakeTransport) ReadRegister(addr uint16, regType RegisterType) (uint16, error) {
+	t.requests++
+	if t.err != nil {
+		return 0, t.err
+	}
+	if err, ok := t.failing[addr]; ok {
+		return 0, err
+	}
+	return t.registers[addr], nil
+}
+
+func (t *fakeTransport) ReadRegisters(addr uint16, quantity uint16, regType RegisterType) ([]uint16, error) {
+	t.requests++
+	if t.err != nil {
+		return nil, t.err
+	}
+	v := make([]uint16, quantity)
+	for i := range v {
+		if err, ok := t.failing[addr+uint16(i)]; ok {
+			return nil, err
+		}
+		v[i] = t.registers[addr+uint16(i)]
+	}
+	return v, nil
+}
+
+func (t *fakeTransport) WriteRegister(addr uint16, value uint16) error {
+	t.requests++
+	t.writes++
+	if t.err != nil {
+		return t.err
+	}
+	t.registers[addr] = value
+	return nil
+}
+
+func (t *fakeTransport) ReadCoil(addr uint16) (bool, error) {
+	t.requests++
+	if t.err != nil {
+		return false, t.err
+	}
+	return t.coils[addr], nil
+}
+
+func (t *fakeTransport) WriteCoil(addr uint16, value bool) error {
+	t.requests++
+	t.coilWrites++
+	if t.err != nil {
+		return t.err
+	}
+	t.coils[addr] = value
+	return nil
+}
+
+func (t *fakeTransport) ExecutePDU(functionCode uint8, data []byte) ([]byte, error) {
+	t.requests++
+	if t.err != nil {
+		return nil, t.err
+	}
+	if t.pdu == nil {
+		return nil, ErrPDUNotSupported
+	}
+	return t.pdu(functionCode, data)
+}
@@ -118,6 +118,47 @@ type ArrayFaultDetails struct {
 	ProcessorSupplyFault         bool
 }
 
+func (v ArrayFaultDetails) ActiveFlags() []string {
+	flags := []string{}
+	if v.OvercurrentPhase1 {
+		flags = append(flags, "OvercurrentPhase1")
+	}
+	if v.FETsShorted {
+		flags = append(flags, "FETsShorted")
+	}
+	if v.SoftwareBug {
+		flags = append(flags, "SoftwareBug")
+	}
+	if v.BatteryHighVoltageDisconnect {
+		flags = append(flags, "BatteryHighVoltageDisconnect")
+	}
+	if v.ArrayHighVoltageDisconnect {
+		flags = append(flags, "ArrayHighVoltageDisconnect")
+	}
+	if v.EEPROMSettingEdit {
+		flags = append(flags, "EEPROMSettingEdit")
+	}
+	if v.RTSShorted {
+		flags = append(flags, "RTSShorted")
+	}
+	if v.RTSWasValidNowDisconnected {
+		flags = append(flags, "RTSWasValidNowDisconnected")
+	}
+	if v.LocalTemperatureSensorFailed {
+		flags = append(flags, "LocalTemperatureSensorFailed")
+	}
+	if v.BatteryLowVoltageDisconnect {
+		flags = append(flags, "BatteryLowVoltageDisconnect")
+	}
+	if v.DIPSwitchChanged {
+		flags = append(flags, "DIPSwitchChanged")
+	}
+	if v.ProcessorSupplyFault {
+		flags = append(flags, "ProcessorSupplyFault")
+	}
+	return flags
+}
+
 type LoadStatus struct {
 	LoadState                        *LoadState        // load_state
 	LoadFault                        *LoadFaultDetails // load_fault
@@ -195,15 +236,157 @@ type LoadFaultDetails struct {
 	ProcessorSupplyFault    bool
 }
 
+func (v LoadFaultDetails) ActiveFlags() []string {
+	flags := []string{}
+	if v.ExternalShortCircuit {
+		flags = append(flags, "ExternalShortCircuit")
+	}
+	if v.Overcurrent {
+		flags = append(flags, "Overcurrent")
+	}
+	if v.FETsShorted {
+		flags = append(flags, "FETsShorted")
+	}
+	if v.SoftwareBug {
+		flags = append(flags, "SoftwareBug")
+	}
+	if v.HighVoltageDisconnect {
+		flags = append(flags, "HighVoltageDisconnect")
+	}
+	if v.HeatsinkOverTemperature {
+		flags = append(flags, "HeatsinkOverTemperature")
+	}
+	if v.DIPSwitchChanged {
+		flags = append(flags, "DIPSwitchChanged")
+	}
+	if v.EEPROMSettingEdit {
+		flags = append(flags, "EEPROMSettingEdit")
+	}
+	if v.FP10Fault {
+		flags = append(flags, "FP10Fault")
+	}
+	if v.ProcessorSupplyFault {
+		flags = append(flags, "ProcessorSupplyFault")
+	}
+	return flags
+}
+
 type MiscData struct {
 	Hourmeter            *uint32               // hours, hourmeter
 	Alarm                *AlarmDetails         // alarm
 	DIPSwitch            *uint16               // dip_switch
+	DIPSwitchDetails     *DIPSwitchDetails     // dip_switch, decoded
 	LEDState             *LEDState             // led_state
 	ChargeStatusLEDState *ChargeStatusLEDState // charge_led_state
 	LightingShouldBeOn   *uint16               // lighting_should_be_on
 }
 
+type DIPSwitch uint16
+
+func (v DIPSwitch) Details() DIPSwitchDetails {
+	return DIPSwitchDetails{
+		Raw:          uint16(v),
+		LightingMode: checkBit(uint16(v), 0),
+		LVDSetting:   LVDSetting(getBits(uint16(v), 1, 0x1)<<1 | getBits(uint16(v), 2, 0x1)),
+		BatteryType:  BatteryType(getBits(uint16(v), 3, 0x1)<<2 | getBits(uint16(v), 4, 0x1)<<1 | getBits(uint16(v), 5, 0x1)),
+		AutoEqualize: checkBit(uint16(v), 6),
+		Modbus:       checkBit(uint16(v), 7),
+	}
+}
+
+type DIPSwitchDetails struct {
+	Raw          uint16
+	LightingMode bool        // switch 1: lighting control mode (off: normal load control)
+	LVDSetting   LVDSetting  // switches 2-3
+	BatteryType  BatteryType // switches 4-6
+	AutoEqualize bool        // switch 7: automatic equalization (off: manual)
+	Modbus       bool        // switch 8: Modbus (off: MeterBus)
+}
+
+// CustomSettings reports whether the charge and load settings stored in EEPROM are in effect,
+// which is the case only when switches 4-6 are all on.
+func (v DIPSwitchDetails) CustomSettings() bool {
+	return v.BatteryType == BatteryTypeCustom
+}
+
+// Warnings describes the EEPROM settings that are overridden by the DIP switches.
+func (v DIPSwitchDetails) Warnings() []string {
+	if v.CustomSettings() {
+		return nil
+	}
+	equalize := "manual"
+	if v.AutoEqualize {
+		equalize = "automatic"
+	}
+	return []string{
+		fmt.Sprintf("DIP switches 4-6 select battery type %s: the charge settings stored in EEPROM are not used", v.BatteryType),
+		fmt.Sprintf("DIP switches 2-3 select %s: the load settings stored in EEPROM are not used", v.LVDSetting),
+		fmt.Sprintf("DIP switch 7 selects %s equalization: the equalization settings stored in EEPROM are not used", equalize),
+	}
+}
+
+// LVDSetting is the LVD selection of switches 2 (high bit) and 3 (low bit).
+type LVDSetting uint16
+
+const (
+	LVDSetting1 LVDSetting = iota
+	LVDSetting2
+	LVDSetting3
+	LVDSetting4
+)
+
+func (v LVDSetting) String() string {
+	switch v {
+	case LVDSetting1:
+		return "LVD setting 1"
+	case LVDSetting2:
+		return "LVD setting 2"
+	case LVDSetting3:
+		return "LVD setting 3"
+	case LVDSetting4:
+		return "LVD setting 4"
+	default:
+		return fmt.Sprintf("0x%04x", uint16(v))
+	}
+}
+
+// BatteryType is the standard battery type selection of switches 4 (high bit) to 6 (low bit).
+type BatteryType uint16
+
+const (
+	BatteryTypeGel BatteryType = iota
+	BatteryTypeSealed
+	BatteryTypeAGMFlooded
+	BatteryTypeFlooded
+	BatteryTypeFloodedHigh
+	BatteryTypeL16
+	BatteryTypeNiCd
+	BatteryTypeCustom
+)
+
+func (v BatteryType) String() string {
+	switch v {
+	case BatteryTypeGel:
+		return "Gel"
+	case BatteryTypeSealed:
+		return "Sealed"
+	case BatteryTypeAGMFlooded:
+		return "AGM/Flooded"
+	case BatteryTypeFlooded:
+		return "Flooded"
+	case BatteryTypeFloodedHigh:
+		return "Flooded (high)"
+	case BatteryTypeL16:
+		return "L-16"
+	case BatteryTypeNiCd:
+		return "NiCd"
+	case BatteryTypeCustom:
+		return "Custom"
+	default:
+		return fmt.Sprintf("0x%04x", uint16(v))
+	}
+}
+
 type Alarm uint32
 
 func (v Alarm) Details() AlarmDetails {
@@ -258,6 +441,74 @@ type AlarmDetails struct {
 	EEPROMAccessFailure              bool
 }
 
+func (v AlarmDetails) ActiveFlags() []string {
+	flags := []string{}
+	if v.RTSOpen {
+		flags = append(flags, "RTSOpen")
+	}
+	if v.RTSShort {
+		flags = append(flags, "RTSShort")
+	}
+	if v.RTSDisconnected {
+		flags = append(flags, "RTSDisconnected")
+	}
+	if v.HeatsinkTemperatureSensorOpen {
+		flags = append(flags, "HeatsinkTemperatureSensorOpen")
+	}
+	if v.HeatsinkTemperatureSensorShorted {
+		flags = append(flags, "HeatsinkTemperatureSensorShorted")
+	}
+	if v.HeatsinkHot {
+		flags = append(flags, "HeatsinkHot")
+	}
+	if v.CurrentLimit {
+		flags = append(flags, "CurrentLimit")
+	}
+	if v.IOffset {
+		flags = append(flags, "IOffset")
+	}
+	if v.BatterySenseOutOfRange {
+		flags = append(flags, "BatterySenseOutOfRange")
+	}
+	if v.BatterySenseDisconnected {
+		flags = append(flags, "BatterySenseDisconnected")
+	}
+	if v.Uncalibrated {
+		flags = append(flags, "Uncalibrated")
+	}
+	if v.BatteryTemperatureOutOfRange {
+		flags = append(flags, "BatteryTemperatureOutOfRange")
+	}
+	if v.FP10SupplyOutOfRange {
+		flags = append(flags, "FP10SupplyOutOfRange")
+	}
+	if v.FETOpen {
+		flags = append(flags, "FETOpen")
+	}
+	if v.IAOffset {
+		flags = append(flags, "IAOffset")
+	}
+	if v.ILOffset {
+		flags = append(flags, "ILOffset")
+	}
+	if v.SupplyOutOfRange {
+		flags = append(flags, "SupplyOutOfRange")
+	}
+	if v.Reset {
+		flags = append(flags, "Reset")
+	}
+	if v.LVD {
+		flags = append(flags, "LVD")
+	}
+	if v.LogTimeout {
+		flags = append(flags, "LogTimeout")
+	}
+	if v.EEPROMAccessFailure {
+		flags = append(flags, "EEPROMAccessFailure")
+	}
+	return flags
+}
+
 type LEDState uint16
 
 const (
@@ -449,3 +700,39 @@ type LoggedDataRecord struct {
 	TimeInEqualizeDaily        uint16            // min,   time_eq_daily     Time in Equalize – daily
 	TimeInFloatDaily           uint16            // min,   time_fl_daily     Time in Float – daily
 }
+
+type Coil uint16
+
+const (
+	CoilEqualizeTriggered  Coil = 0x0000 // eq_trigger         Equalize Triggered (set to start, clear to stop)
+	CoilLoadDisconnect     Coil = 0x0001 // load_disconnect    Load Disconnect
+	CoilClearAhResettable  Coil = 0x0010 // clear_ah_reset     Clear Ah Resettable
+	CoilClearKWhResettable Coil = 0x0012 // clear_kwh_reset    Clear kWh Resettable
+	CoilClearFaults        Coil = 0x0014 // clear_faults       Clear Faults
+	CoilClearAlarms        Coil = 0x0015 // clear_alarms       Clear Alarms
+	CoilClearLoggedData    Coil = 0x0017 // clear_log          Clear Logged Data
+	CoilResetControl       Coil = 0x00ff // reset_control      Reset Control (restart controller)
+)
+
+func (v Coil) String() string {
+	switch v {
+	case CoilEqualizeTriggered:
+		return "Equalize Triggered"
+	case CoilLoadDisconnect:
+		return "Load Disconnect"
+	case CoilClearAhResettable:
+		return "Clear Ah Resettable"
+	case CoilClearKWhResettable:
+		return "Clear kWh Resettable"
+	case CoilClearFaults:
+		return "Clear Faults"
+	case CoilClearAlarms:
+		return "Clear Alarms"
+	case CoilClearLoggedData:
+		return "Clear Logged Data"
+	case CoilResetControl:
+		return "Reset Control"
+	default:
+		return fmt.Sprintf("0x%04x", uint16(v))
+	}
+}
@@ -0,0 +1,293 @@
+package prostar_pwm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrInvalidSettings = errors.New("invalid settings")
+
+// FieldError describes a single offending settings field. RelatedField is set for cross-field
+// checks and names the field that Field was compared against.
+type FieldError struct {
+	Field        string
+	RelatedField string
+	Value        any
+	Message      string
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+type ValidationError struct {
+	Errors []*FieldError
+}
+
+func (e *ValidationError) Error() string {
+	var messages []string
+	for _, fieldError := range e.Errors {
+		messages = append(messages, fieldError.Error())
+	}
+	return fmt.Sprintf("%s: %s", ErrInvalidSettings, strings.Join(messages, "; "))
+}
+
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrInvalidSettings
+}
+
+func (e *ValidationError) Unwrap() []error {
+	var errs []error
+	for _, fieldError := range e.Errors {
+		errs = append(errs, fieldError)
+	}
+	return errs
+}
+
+// Rated charge current of the ProStar PWM models, in A, for ValidatePWMSettings.
+const (
+	MaxChargeCurrentPS15 float32 = 15 // PS-15 and PS-15M
+	MaxChargeCurrentPS30 float32 = 30 // PS-30 and PS-30M
+)
+
+type validator struct {
+	scale float32
+	errs  []*FieldError
+}
+
+func newValidator(systemVoltage int) (*validator, error) {
+	switch systemVoltage {
+	case 12:
+		return &validator{scale: 1}, nil
+	case 24:
+		return &validator{scale: 2}, nil
+	}
+	return nil, fmt.Errorf("invalid system voltage: %d", systemVoltage)
+}
+
+func (v *validator) err() error {
+	if len(v.errs) == 0 {
+		return nil
+	}
+	return &ValidationError{Errors: v.errs}
+}
+
+// checkVoltage checks a voltage field against its 12 V range, doubled for 24 V systems.
+func (v *validator) checkVoltage(field string, value *float32, min float32, max float32) {
+	if value == nil {
+		return
+	}
+	min *= v.scale
+	max *= v.scale
+	if (*value < min) || (*value > max) {
+		v.errs = append(v.errs, &FieldError{
+			Field:   field,
+			Value:   *value,
+			Message: fmt.Sprintf("%g is outside %g..%g", *value, min, max),
+		})
+	}
+}
+
+func checkRange[T uint16 | int16](v *validator, field string, value *T, min T, max T) {
+	if value == nil {
+		return
+	}
+	if (*value < min) || (*value > max) {
+		v.errs = append(v.errs, &FieldError{
+			Field:   field,
+			Value:   *value,
+			Message: fmt.Sprintf("%d is outside %d..%d", *value, min, max),
+		})
+	}
+}
+
+// checkBelow checks that field a is below (or, if orEqual is set, not above) field b, where each
+// value is the proposed one if set and the current one otherwise. The check is skipped if neither
+// field is proposed or either value is unknown. The error names the proposed field, preferring a.
+func checkBelow[T float32 | int16](v *validator, fieldA string, proposedA *T, currentA *T, fieldB string, proposedB *T, currentB *T, orEqual bool) {
+	if (proposedA == nil) && (proposedB == nil) {
+		return
+	}
+	a := mergePtr(proposedA, currentA)
+	b := mergePtr(proposedB, currentB)
+	if (a == nil) || (b == nil) {
+		return
+	}
+	if (*a < *b) || (orEqual && (*a == *b)) {
+		return
+	}
+	if proposedA != nil {
+		relation := "below"
+		if orEqual {
+			relation = "at or below"
+		}
+		v.errs = append(v.errs, &FieldError{
+			Field:        fieldA,
+			RelatedField: fieldB,
+			Value:        *a,
+			Message:      fmt.Sprintf("%v must be %s %s (%v)", *a, relation, fieldB, *b),
+		})
+	} else {
+		relation := "above"
+		if orEqual {
+			relation = "at or above"
+		}
+		v.errs = append(v.errs, &FieldError{
+			Field:        fieldB,
+			RelatedField: fieldA,
+			Value:        *b,
+			Message:      fmt.Sprintf("%v must be %s %s (%v)", *b, relation, fieldA, *a),
+		})
+	}
+}
+
+func mergePtr[T any](proposed *T, current *T) *T {
+	if proposed != nil {
+		return proposed
+	}
+	return current
+}
+
+// ValidateChargeSettings checks the proposed charge settings for a 12 V or 24 V system. Fields left
+// nil in proposed are taken from current (typically the values read from the device) for the
+// cross-field checks.
+func ValidateChargeSettings(proposed ChargeSettings, current ChargeSettings, systemVoltage int) error {
+	v, err := newValidator(systemVoltage)
+	if err != nil {
+		return err
+	}
+
+	v.checkVoltage("RegulationVoltageAt25C", proposed.RegulationVoltageAt25C, 13.0, 15.5)
+	v.checkVoltage("FloatVoltageAt25C", proposed.FloatVoltageAt25C, 12.5, 14.5)
+	checkRange(v, "TimeBeforeEnteringFloat", proposed.TimeBeforeEnteringFloat, 0, 28800)
+	checkRange(v, "TimeBeforeEnteringFloatDueToLowBattery", proposed.TimeBeforeEnteringFloatDueToLowBattery, 0, 28800)
+	v.checkVoltage("VoltageTriggerForLowBatteryFloatTime", proposed.VoltageTriggerForLowBatteryFloatTime, 11.0, 13.5)
+	v.checkVoltage("VoltageToCancelFloat", proposed.VoltageToCancelFloat, 11.0, 13.5)
+	checkRange(v, "ExitFloatTime", proposed.ExitFloatTime, 0, 28800)
+	v.checkVoltage("EqualizeVoltageAt25C", proposed.EqualizeVoltageAt25C, 13.0, 16.0)
+	checkRange(v, "DaysBetweenEQCycles", proposed.DaysBetweenEQCycles, 0, 255)
+	checkRange(v, "EqualizeTimeLimitAboveEVReg", proposed.EqualizeTimeLimitAboveEVReg, 0, 28800)
+	checkRange(v, "EqualizeTimeLimitAtEVEq", proposed.EqualizeTimeLimitAtEVEq, 0, 28800)
+	v.checkVoltage("ReferenceChargeVoltageLimit", proposed.ReferenceChargeVoltageLimit, 13.0, 17.0)
+	v.checkVoltage("TemperatureCompensationCoefficient", proposed.TemperatureCompensationCoefficient, 0, 0.05)
+	v.checkVoltage("HighVoltageDisconnectAt25C", proposed.HighVoltageDisconnectAt25C, 13.5, 17.0)
+	v.checkVoltage("HighVoltageReconnect", proposed.HighVoltageReconnect, 13.0, 16.5)
+	if (proposed.MaximumChargeVoltageReference != nil) && (*proposed.MaximumChargeVoltageReference != 0) {
+		// 0 disables the limit
+		v.checkVoltage("MaximumChargeVoltageReference", proposed.MaximumChargeVoltageReference, 13.0, 17.0)
+	}
+	checkRange(v, "MaxBatteryTempCompensationLimit", proposed.MaxBatteryTempCompensationLimit, -40, 80)
+	checkRange(v, "MinBatteryTempCompensationLimit", proposed.MinBatteryTempCompensationLimit, -40, 80)
+
+	checkBelow(v, "FloatVoltageAt25C", proposed.FloatVoltageAt25C, current.FloatVoltageAt25C, "RegulationVoltageAt25C", proposed.RegulationVoltageAt25C, current.RegulationVoltageAt25C, true)
+	checkBelow(v, "RegulationVoltageAt25C", proposed.RegulationVoltageAt25C, current.RegulationVoltageAt25C, "EqualizeVoltageAt25C", proposed.EqualizeVoltageAt25C, current.EqualizeVoltageAt25C, true)
+	checkBelow(v, "RegulationVoltageAt25C", proposed.RegulationVoltageAt25C, current.RegulationVoltageAt25C, "HighVoltageDisconnectAt25C", proposed.HighVoltageDisconnectAt25C, current.HighVoltageDisconnectAt25C, false)
+	checkBelow(v, "EqualizeVoltageAt25C", proposed.EqualizeVoltageAt25C, current.EqualizeVoltageAt25C, "HighVoltageDisconnectAt25C", proposed.HighVoltageDisconnectAt25C, current.HighVoltageDisconnectAt25C, false)
+	checkBelow(v, "HighVoltageReconnect", proposed.HighVoltageReconnect, current.HighVoltageReconnect, "HighVoltageDisconnectAt25C", proposed.HighVoltageDisconnectAt25C, current.HighVoltageDisconnectAt25C, false)
+	checkBelow(v, "VoltageToCancelFloat", proposed.VoltageToCancelFloat, current.VoltageToCancelFloat, "FloatVoltageAt25C", proposed.FloatVoltageAt25C, current.FloatVoltageAt25C, false)
+	checkBelow(v, "RegulationVoltageAt25C", proposed.RegulationVoltageAt25C, current.RegulationVoltageAt25C, "ReferenceChargeVoltageLimit", proposed.ReferenceChargeVoltageLimit, current.ReferenceChargeVoltageLimit, true)
+	if maximumReference := mergePtr(proposed.MaximumChargeVoltageReference, current.MaximumChargeVoltageReference); (maximumReference != nil) && (*maximumReference != 0) {
+		checkBelow(v, "RegulationVoltageAt25C", proposed.RegulationVoltageAt25C, current.RegulationVoltageAt25C, "MaximumChargeVoltageReference", proposed.MaximumChargeVoltageReference, current.MaximumChargeVoltageReference, true)
+	}
+	checkBelow(v, "MinBatteryTempCompensationLimit", proposed.MinBatteryTempCompensationLimit, current.MinBatteryTempCompensationLimit, "MaxBatteryTempCompensationLimit", proposed.MaxBatteryTempCompensationLimit, current.MaxBatteryTempCompensationLimit, false)
+
+	return v.err()
+}
+
+// ValidateLoadSettings checks the proposed load settings. See ValidateChargeSettings.
+func ValidateLoadSettings(proposed LoadSettings, current LoadSettings, systemVoltage int) error {
+	v, err := newValidator(systemVoltage)
+	if err != nil {
+		return err
+	}
+
+	v.checkVoltage("LowVoltageDisconnect", proposed.LowVoltageDisconnect, 10.0, 13.0)
+	v.checkVoltage("LowVoltageReconnect", proposed.LowVoltageReconnect, 11.0, 14.0)
+	v.checkVoltage("LoadHighVoltageDisconnect", proposed.LoadHighVoltageDisconnect, 13.5, 17.0)
+	v.checkVoltage("LoadHighVoltageReconnect", proposed.LoadHighVoltageReconnect, 13.0, 16.5)
+	v.checkVoltage("LVDLoadCurrentCompensation", proposed.LVDLoadCurrentCompensation, 0, 0.1)
+	checkRange(v, "LVDWarningTimeout", proposed.LVDWarningTimeout, 0, 300)
+
+	checkBelow(v, "LowVoltageDisconnect", proposed.LowVoltageDisconnect, current.LowVoltageDisconnect, "LowVoltageReconnect", proposed.LowVoltageReconnect, current.LowVoltageReconnect, false)
+	checkBelow(v, "LoadHighVoltageReconnect", proposed.LoadHighVoltageReconnect, current.LoadHighVoltageReconnect, "LoadHighVoltageDisconnect", proposed.LoadHighVoltageDisconnect, current.LoadHighVoltageDisconnect, false)
+	checkBelow(v, "LowVoltageReconnect", proposed.LowVoltageReconnect, current.LowVoltageReconnect, "LoadHighVoltageReconnect", proposed.LoadHighVoltageReconnect, current.LoadHighVoltageReconnect, false)
+
+	return v.err()
+}
+
+// ValidateMiscSettings checks the proposed misc settings. See ValidateChargeSettings.
+func ValidateMiscSettings(proposed MiscSettings, current MiscSettings, systemVoltage int) error {
+	v, err := newValidator(systemVoltage)
+	if err != nil {
+		return err
+	}
+
+	v.checkVoltage("LEDGreenToGreenAndYellowLimit", proposed.LEDGreenToGreenAndYellowLimit, 11.0, 14.5)
+	v.checkVoltage("LEDGreenAndYellowToYellowLimit", proposed.LEDGreenAndYellowToYellowLimit, 11.0, 14.5)
+	v.checkVoltage("LEDYellowToYellowAndRedLimit", proposed.LEDYellowToYellowAndRedLimit, 11.0, 14.5)
+	v.checkVoltage("LEDYellowAndRedToRedFlashingLimit", proposed.LEDYellowAndRedToRedFlashingLimit, 11.0, 14.5)
+	checkRange(v, "ModbusID", proposed.ModbusID, 1, 247)
+	checkRange(v, "MeterbusID", proposed.MeterbusID, 1, 15)
+
+	checkBelow(v, "LEDGreenAndYellowToYellowLimit", proposed.LEDGreenAndYellowToYellowLimit, current.LEDGreenAndYellowToYellowLimit, "LEDGreenToGreenAndYellowLimit", proposed.LEDGreenToGreenAndYellowLimit, current.LEDGreenToGreenAndYellowLimit, false)
+	checkBelow(v, "LEDYellowToYellowAndRedLimit", proposed.LEDYellowToYellowAndRedLimit, current.LEDYellowToYellowAndRedLimit, "LEDGreenAndYellowToYellowLimit", proposed.LEDGreenAndYellowToYellowLimit, current.LEDGreenAndYellowToYellowLimit, false)
+	checkBelow(v, "LEDYellowAndRedToRedFlashingLimit", proposed.LEDYellowAndRedToRedFlashingLimit, current.LEDYellowAndRedToRedFlashingLimit, "LEDYellowToYellowAndRedLimit", proposed.LEDYellowToYellowAndRedLimit, current.LEDYellowToYellowAndRedLimit, false)
+
+	return v.err()
+}
+
+// ValidatePWMSettings checks the proposed PWM settings for a controller rated for maxChargeCurrent
+// (MaxChargeCurrentPS15 or MaxChargeCurrentPS30).
+func ValidatePWMSettings(proposed PWMSettings, maxChargeCurrent float32) error {
+	v := &validator{scale: 1}
+
+	if (proposed.ChargeCurrentLimit != nil) && ((*proposed.ChargeCurrentLimit < 0) || (*proposed.ChargeCurrentLimit > maxChargeCurrent)) {
+		v.errs = append(v.errs, &FieldError{
+			Field:   "ChargeCurrentLimit",
+			Value:   *proposed.ChargeCurrentLimit,
+			Message: fmt.Sprintf("%g is outside 0..%g", *proposed.ChargeCurrentLimit, maxChargeCurrent),
+		})
+	}
+
+	return v.err()
+}
+
+func (dev *Dev) ValidateChargeSettings(s ChargeSettings, systemVoltage int) error {
+	return dev.ValidateChargeSettingsContext(context.Background(), s, systemVoltage)
+}
+
+// ValidateChargeSettingsContext validates s against the current device settings for a 12 V or 24 V
+// system. The controller does not report its system voltage, so it is up to the caller.
+func (dev *Dev) ValidateChargeSettingsContext(ctx context.Context, s ChargeSettings, systemVoltage int) error {
+	current, err := dev.ReadChargeSettingsContext(ctx)
+	if err != nil {
+		return err
+	}
+	return ValidateChargeSettings(s, current, systemVoltage)
+}
+
+func (dev *Dev) ValidateLoadSettings(s LoadSettings, systemVoltage int) error {
+	return dev.ValidateLoadSettingsContext(context.Background(), s, systemVoltage)
+}
+
+func (dev *Dev) ValidateLoadSettingsContext(ctx context.Context, s LoadSettings, systemVoltage int) error {
+	current, err := dev.ReadLoadSettingsContext(ctx)
+	if err != nil {
+		return err
+	}
+	return ValidateLoadSettings(s, current, systemVoltage)
+}
+
+func (dev *Dev) ValidateMiscSettings(s MiscSettings, systemVoltage int) error {
+	return dev.ValidateMiscSettingsContext(context.Background(), s, systemVoltage)
+}
+
+func (dev *Dev) ValidateMiscSettingsContext(ctx context.Context, s MiscSettings, systemVoltage int) error {
+	current, err := dev.ReadMiscSettingsContext(ctx)
+	if err != nil {
+		return err
+	}
+	return ValidateMiscSettings(s, current, systemVoltage)
+}
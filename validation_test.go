@@ -0,0 +1,244 @@
+package prostar_pwm
+
+import (
+	"errors"
+	"testing"
+)
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name         string
+		validate     func() error
+		field        string // "" if the settings are valid
+		relatedField string
+	}{
+		{
+			name: "valid charge settings",
+			validate: func() error {
+				return ValidateChargeSettings(ChargeSettings{
+					RegulationVoltageAt25C: ptr[float32](14.4),
+					FloatVoltageAt25C:      ptr[float32](13.7),
+					EqualizeVoltageAt25C:   ptr[float32](15.1),
+				}, ChargeSettings{}, 12)
+			},
+		},
+		{
+			name: "float above regulation",
+			validate: func() error {
+				return ValidateChargeSettings(ChargeSettings{
+					RegulationVoltageAt25C: ptr[float32](14.0),
+					FloatVoltageAt25C:      ptr[float32](14.2),
+				}, ChargeSettings{}, 12)
+			},
+			field:        "FloatVoltageAt25C",
+			relatedField: "RegulationVoltageAt25C",
+		},
+		{
+			name: "equalize below regulation",
+			validate: func() error {
+				return ValidateChargeSettings(ChargeSettings{
+					RegulationVoltageAt25C: ptr[float32](14.4),
+					EqualizeVoltageAt25C:   ptr[float32](14.2),
+				}, ChargeSettings{}, 12)
+			},
+			field:        "RegulationVoltageAt25C",
+			relatedField: "EqualizeVoltageAt25C",
+		},
+		{
+			name: "float above current regulation",
+			validate: func() error {
+				return ValidateChargeSettings(ChargeSettings{
+					FloatVoltageAt25C: ptr[float32](14.45),
+				}, ChargeSettings{
+					RegulationVoltageAt25C: ptr[float32](14.4),
+				}, 12)
+			},
+			field:        "FloatVoltageAt25C",
+			relatedField: "RegulationVoltageAt25C",
+		},
+		{
+			name: "regulation below current float",
+			validate: func() error {
+				return ValidateChargeSettings(ChargeSettings{
+					RegulationVoltageAt25C: ptr[float32](13.5),
+				}, ChargeSettings{
+					FloatVoltageAt25C:    ptr[float32](13.7),
+					EqualizeVoltageAt25C: ptr[float32](15.1),
+				}, 12)
+			},
+			field:        "RegulationVoltageAt25C",
+			relatedField: "FloatVoltageAt25C",
+		},
+		{
+			name: "current settings inconsistent but not proposed",
+			validate: func() error {
+				return ValidateChargeSettings(ChargeSettings{
+					DaysBetweenEQCycles: ptr[uint16](28),
+				}, ChargeSettings{
+					RegulationVoltageAt25C: ptr[float32](14.0),
+					FloatVoltageAt25C:      ptr[float32](14.2),
+				}, 12)
+			},
+		},
+		{
+			name: "24 V regulation within the doubled range",
+			validate: func() error {
+				return ValidateChargeSettings(ChargeSettings{
+					RegulationVoltageAt25C: ptr[float32](28.8),
+				}, ChargeSettings{}, 24)
+			},
+		},
+		{
+			name: "12 V value for a 24 V system",
+			validate: func() error {
+				return ValidateChargeSettings(ChargeSettings{
+					RegulationVoltageAt25C: ptr[float32](14.4),
+				}, ChargeSettings{}, 24)
+			},
+			field: "RegulationVoltageAt25C",
+		},
+		{
+			name: "24 V value for a 12 V system",
+			validate: func() error {
+				return ValidateLoadSettings(LoadSettings{
+					LowVoltageDisconnect: ptr[float32](23.0),
+				}, LoadSettings{}, 12)
+			},
+			field: "LowVoltageDisconnect",
+		},
+		{
+			name: "LVR equal to LVD",
+			validate: func() error {
+				return ValidateLoadSettings(LoadSettings{
+					LowVoltageDisconnect: ptr[float32](11.5),
+					LowVoltageReconnect:  ptr[float32](11.5),
+				}, LoadSettings{}, 12)
+			},
+			field:        "LowVoltageDisconnect",
+			relatedField: "LowVoltageReconnect",
+		},
+		{
+			name: "LVR below current LVD",
+			validate: func() error {
+				return ValidateLoadSettings(LoadSettings{
+					LowVoltageReconnect: ptr[float32](11.2),
+				}, LoadSettings{
+					LowVoltageDisconnect: ptr[float32](11.5),
+				}, 12)
+			},
+			field:        "LowVoltageReconnect",
+			relatedField: "LowVoltageDisconnect",
+		},
+		{
+			name: "HVR equal to HVD",
+			validate: func() error {
+				return ValidateLoadSettings(LoadSettings{
+					LoadHighVoltageDisconnect: ptr[float32](15.3),
+					LoadHighVoltageReconnect:  ptr[float32](15.3),
+				}, LoadSettings{}, 12)
+			},
+			field:        "LoadHighVoltageReconnect",
+			relatedField: "LoadHighVoltageDisconnect",
+		},
+		{
+			name: "charge HVR above HVD",
+			validate: func() error {
+				return ValidateChargeSettings(ChargeSettings{
+					HighVoltageDisconnectAt25C: ptr[float32](15.2),
+					HighVoltageReconnect:       ptr[float32](15.4),
+				}, ChargeSettings{}, 12)
+			},
+			field:        "HighVoltageReconnect",
+			relatedField: "HighVoltageDisconnectAt25C",
+		},
+		{
+			name: "LED thresholds in order",
+			validate: func() error {
+				return ValidateMiscSettings(MiscSettings{
+					LEDGreenToGreenAndYellowLimit:     ptr[float32](13.3),
+					LEDGreenAndYellowToYellowLimit:    ptr[float32](13.0),
+					LEDYellowToYellowAndRedLimit:      ptr[float32](12.65),
+					LEDYellowAndRedToRedFlashingLimit: ptr[float32](12.3),
+				}, MiscSettings{}, 12)
+			},
+		},
+		{
+			name: "LED thresholds out of order",
+			validate: func() error {
+				return ValidateMiscSettings(MiscSettings{
+					LEDYellowToYellowAndRedLimit: ptr[float32](13.1),
+				}, MiscSettings{
+					LEDGreenToGreenAndYellowLimit:     ptr[float32](13.3),
+					LEDGreenAndYellowToYellowLimit:    ptr[float32](13.0),
+					LEDYellowAndRedToRedFlashingLimit: ptr[float32](12.3),
+				}, 12)
+			},
+			field:        "LEDYellowToYellowAndRedLimit",
+			relatedField: "LEDGreenAndYellowToYellowLimit",
+		},
+		{
+			name: "Modbus ID out of range",
+			validate: func() error {
+				return ValidateMiscSettings(MiscSettings{
+					ModbusID: ptr[uint16](248),
+				}, MiscSettings{}, 12)
+			},
+			field: "ModbusID",
+		},
+		{
+			name: "charge current above the PS-15 rating",
+			validate: func() error {
+				return ValidatePWMSettings(PWMSettings{
+					ChargeCurrentLimit: ptr[float32](20),
+				}, MaxChargeCurrentPS15)
+			},
+			field: "ChargeCurrentLimit",
+		},
+		{
+			name: "charge current within the PS-30 rating",
+			validate: func() error {
+				return ValidatePWMSettings(PWMSettings{
+					ChargeCurrentLimit: ptr[float32](20),
+				}, MaxChargeCurrentPS30)
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.validate()
+			if test.field == "" {
+				if err != nil {
+					t.Fatalf("err = %v, want nil", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrInvalidSettings) {
+				t.Fatalf("err = %v, want %v", err, ErrInvalidSettings)
+			}
+			var fieldError *FieldError
+			if !errors.As(err, &fieldError) {
+				t.Fatalf("err = %v, want a *FieldError", err)
+			}
+			if (fieldError.Field != test.field) || (fieldError.RelatedField != test.relatedField) {
+				t.Errorf("field = %q, related field = %q, want %q and %q", fieldError.Field, fieldError.RelatedField, test.field, test.relatedField)
+			}
+		})
+	}
+}
+
+func TestValidateUnsupportedSystemVoltage(t *testing.T) {
+	for _, validate := range []func() error{
+		func() error { return ValidateChargeSettings(ChargeSettings{}, ChargeSettings{}, 48) },
+		func() error { return ValidateLoadSettings(LoadSettings{}, LoadSettings{}, 48) },
+		func() error { return ValidateMiscSettings(MiscSettings{}, MiscSettings{}, 48) },
+	} {
+		err := validate()
+		if err == nil {
+			t.Errorf("err = nil for a 48 V system")
+		}
+	}
+}